@@ -9,8 +9,11 @@ import (
 	"time"
 
 	"github.com/pgvillage-tools/pgfga/internal/version"
+	"github.com/pgvillage-tools/pgfga/pkg/credential"
+	"github.com/pgvillage-tools/pgfga/pkg/duration"
 	"github.com/pgvillage-tools/pgfga/pkg/ldap"
 	"github.com/pgvillage-tools/pgfga/pkg/pg"
+	"github.com/pgvillage-tools/pgfga/pkg/secret"
 	"go.uber.org/zap/zapcore"
 
 	"gopkg.in/yaml.v2"
@@ -27,28 +30,74 @@ const (
 
 // FgaGeneralConfig is a definition of the config yaml file that can be used by PgFga
 type FgaGeneralConfig struct {
-	LogLevel zapcore.Level `yaml:"loglevel"`
-	RunDelay time.Duration `yaml:"run_delay"`
-	Debug    bool          `yaml:"debug"`
+	LogLevel           zapcore.Level         `yaml:"loglevel"`
+	RunDelay           time.Duration         `yaml:"run_delay"`
+	Debug              bool                  `yaml:"debug"`
+	PasswordEncryption pg.PasswordEncryption `yaml:"password_encryption"`
+	// ExpiryPolicy sets a default expiry per auth type, for users that have no expiry set
+	ExpiryPolicy map[string]Expiry `yaml:"expiry_policy"`
 }
 
 // FgaUserConfig holds all generic config regarding PostgreSQL users to be managed with PgFga
 type FgaUserConfig struct {
-	Auth     string    `yaml:"auth"`
-	BaseDN   string    `yaml:"ldapbasedn"`
-	Filter   string    `yaml:"ldapfilter"`
-	MemberOf []string  `yaml:"memberof"`
-	Options  []string  `yaml:"options"`
-	Expiry   time.Time `yaml:"expiry"`
-	Password string    `yaml:"password"`
-	State    pg.State  `yaml:"state"`
+	Auth     string          `yaml:"auth"`
+	BaseDN   string          `yaml:"ldapbasedn"`
+	Filter   string          `yaml:"ldapfilter"`
+	MemberOf []pg.Membership `yaml:"memberof"`
+	// CertNames are the names in client certificates (CN) that map to this user (auth: clientcert, defaults to the
+	// name of the user)
+	CertNames []string `yaml:"cert_names"`
+	// Profile is the name of a role profile with options, memberships and attributes that this user inherits
+	Profile string `yaml:"profile"`
+	// PreviousNames are renamed to the name of this user when it does not exist yet
+	PreviousNames []string `yaml:"previous_names"`
+	// MemberPreviousNames holds previous names per user for users in an ldap group (renamed in the directory)
+	MemberPreviousNames map[string][]string `yaml:"member_previous_names"`
+	// ReassignTo is the role that owned objects are reassigned to when dropped (defaults to the database owner)
+	ReassignTo string `yaml:"reassign_to"`
+	// Force terminates sessions when dropping the user or role fails
+	Force pg.ForceOptions `yaml:"force"`
+	// SoftDelete renames the user or role instead of dropping it, and drops it after a retention period
+	SoftDelete pg.SoftDelete `yaml:"soft_delete"`
+	// AuthoritativeOptions resets all options that are not in Options (defaults to strict.role_options)
+	AuthoritativeOptions *bool `yaml:"authoritative_options"`
+	// AuthoritativeMemberships revokes all memberships that are not in MemberOf (defaults to strict.memberships)
+	AuthoritativeMemberships *bool                 `yaml:"authoritative_memberships"`
+	Options                  []string              `yaml:"options"`
+	Expiry                   Expiry                `yaml:"expiry"`
+	Password                 credential.Credential `yaml:"password"`
+	ConnectionLimit          *int                  `yaml:"connection_limit"`
+	Settings                 pg.Settings           `yaml:"settings"`
+	State                    pg.State              `yaml:"state"`
+	// PasswordLength, RotateAfter, KeepPrevious and Output are used for users with auth: generated
+	PasswordLength int               `yaml:"password_length"`
+	RotateAfter    duration.Duration `yaml:"rotate_after"`
+	KeepPrevious   bool              `yaml:"keep_previous"`
+	Output         secret.Output     `yaml:"output"`
 }
 
 // FgaRoleConfig holds all config regarding PostgreSQL roles to be managed with PgFga
 type FgaRoleConfig struct {
-	Options  []string `yaml:"options"`
-	MemberOf []string `yaml:"member"`
-	State    pg.State `yaml:"state"`
+	Options  []string        `yaml:"options"`
+	MemberOf []pg.Membership `yaml:"member"`
+	// Profile is the name of a role profile with options, memberships and attributes that this role inherits
+	Profile string `yaml:"profile"`
+	// PreviousNames are renamed to the name of this role when it does not exist yet
+	PreviousNames []string `yaml:"previous_names"`
+	// ReassignTo is the role that owned objects are reassigned to when dropped (defaults to the database owner)
+	ReassignTo string `yaml:"reassign_to"`
+	// Force terminates sessions when dropping the user or role fails
+	Force pg.ForceOptions `yaml:"force"`
+	// SoftDelete renames the user or role instead of dropping it, and drops it after a retention period
+	SoftDelete pg.SoftDelete `yaml:"soft_delete"`
+	// AuthoritativeOptions resets all options that are not in Options (defaults to strict.role_options)
+	AuthoritativeOptions *bool `yaml:"authoritative_options"`
+	// AuthoritativeMemberships revokes all memberships that are not in MemberOf (defaults to strict.memberships)
+	AuthoritativeMemberships *bool       `yaml:"authoritative_memberships"`
+	Expiry                   Expiry      `yaml:"expiry"`
+	ConnectionLimit          *int        `yaml:"connection_limit"`
+	Settings                 pg.Settings `yaml:"settings"`
+	State                    pg.State    `yaml:"state"`
 }
 
 // FgaConfig holds all config regarding PostgreSQL roles to be managed with PgFga
@@ -56,11 +105,26 @@ type FgaConfig struct {
 	GeneralConfig FgaGeneralConfig         `yaml:"general"`
 	StrictConfig  pg.StrictOptions         `yaml:"strict"`
 	LdapConfig    ldap.Config              `yaml:"ldap"`
-	PgDsn         pg.ConnParams            `yaml:"postgresql_dsn"`
+	PgDsn         PgDsnConfig              `yaml:"postgresql_dsn"`
+	Tablespaces   pg.Tablespaces           `yaml:"tablespaces"`
 	DbsConfig     pg.Databases             `yaml:"databases"`
 	UserConfig    map[string]FgaUserConfig `yaml:"users"`
 	Roles         map[string]FgaRoleConfig `yaml:"roles"`
-	Slots         []string                 `yaml:"replication_slots"`
+	Slots         pg.ReplicationSlots      `yaml:"replication_slots"`
+	// DatabaseProfiles hold definitions (extensions, schemas, settings, ...) that databases can use as profiles
+	DatabaseProfiles pg.Databases `yaml:"database_profiles"`
+	// DefaultDatabaseProfile is applied to all databases before the profiles of the database itself
+	DefaultDatabaseProfile string `yaml:"default_database_profile"`
+	// RoleProfiles hold options, memberships and attributes that users and roles can inherit
+	RoleProfiles map[string]RoleProfile `yaml:"role_profiles"`
+	// SlotGuardrails are thresholds for all replication slots (retained WAL, inactive time)
+	SlotGuardrails pg.SlotGuardrails `yaml:"slot_guardrails"`
+	// Hba defines if and how pg_hba.conf is generated
+	Hba HbaConfig `yaml:"hba"`
+	// ServerSettings are set for the whole cluster with ALTER SYSTEM (e.a. shared_preload_libraries)
+	ServerSettings pg.Settings `yaml:"server_settings"`
+	// Command holds the (optional) command line arguments after the flags (e.a. report expiring)
+	Command []string `yaml:"-"`
 }
 
 // NewConfig will instantiate a new Config and return it
@@ -92,6 +156,7 @@ func NewConfig() (config FgaConfig, err error) {
 		return config, err
 	}
 	err = yaml.Unmarshal(yamlConfig, &config)
+	config.Command = flag.Args()
 	config.GeneralConfig.Debug = config.GeneralConfig.Debug || debug
 	return config, err
 }
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/pgvillage-tools/pgfga/pkg/credential"
+	"github.com/pgvillage-tools/pgfga/pkg/pg"
+)
+
+// PgDsnConfig holds the parameters to connect to postgres with. The password is a credential, which is resolved
+// when connecting, so that it is never part of the connection parameters.
+type PgDsnConfig struct {
+	Params   pg.ConnParams
+	Password credential.Credential
+}
+
+// UnmarshalYAML reads all connection parameters as strings, and the password as a credential
+func (dsn *PgDsnConfig) UnmarshalYAML(unmarshal func(any) error) error {
+	var params map[pg.ConnParamKey]any
+	if err := unmarshal(&params); err != nil {
+		return err
+	}
+	var password struct {
+		Password credential.Credential `yaml:"password"`
+	}
+	if err := unmarshal(&password); err != nil {
+		return err
+	}
+	*dsn = PgDsnConfig{Params: pg.ConnParams{}, Password: password.Password}
+	for key, value := range params {
+		if key == pg.ConnParamPassword || value == nil {
+			continue
+		}
+		dsn.Params[key] = fmt.Sprint(value)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/pgvillage-tools/pgfga/pkg/credential"
+	"github.com/pgvillage-tools/pgfga/pkg/pg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestPgDsnConfig(t *testing.T) {
+	var dsn PgDsnConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+host: postgres
+connect_timeout: 10
+password:
+  file: /etc/pgfga/secrets/postgres
+`), &dsn))
+	assert.Equal(t, pg.ConnParams{"host": "postgres", "connect_timeout": "10"}, dsn.Params)
+	assert.Equal(t, credential.Credential{File: "/etc/pgfga/secrets/postgres"}, dsn.Password)
+
+	dsn = PgDsnConfig{}
+	require.NoError(t, yaml.Unmarshal([]byte("host: postgres\npassword: secret\n"), &dsn))
+	assert.Equal(t, pg.ConnParams{"host": "postgres"}, dsn.Params)
+	assert.Equal(t, credential.Credential{Value: "secret"}, dsn.Password)
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/pgvillage-tools/pgfga/pkg/duration"
+)
+
+// authWithoutPassword are the auth types where PgFga never sets a password. A relative expiry is only renewed when
+// PgFga sets a new password, so for these auth types it would lock users out after the first period.
+var authWithoutPassword = []string{"ldap-user", "ldap-group", "clientcert"}
+
+// Expiry is either an absolute expiry (e.a. 2030-01-01) or an expiry relative to the moment PgFga sets a new password
+// (e.a. 90d)
+type Expiry struct {
+	At    time.Time
+	After duration.Duration
+}
+
+// UnmarshalYAML converts a yaml date or duration to an Expiry
+func (e *Expiry) UnmarshalYAML(unmarshal func(any) error) error {
+	var at time.Time
+	if err := unmarshal(&at); err == nil {
+		*e = Expiry{At: at}
+		return nil
+	}
+	var after duration.Duration
+	if err := unmarshal(&after); err != nil {
+		return fmt.Errorf("expiry should be a date or a duration: %w", err)
+	}
+	*e = Expiry{After: after}
+	return nil
+}
+
+// IsZero returns true if no expiry is set
+func (e Expiry) IsZero() bool {
+	return e.At.IsZero() && e.After == 0
+}
+
+// relativeWithoutPassword returns true if an expiry is relative, and the auth type is one where PgFga never sets a
+// password
+func (e Expiry) relativeWithoutPassword(auth string) bool {
+	return e.After > 0 && slices.Contains(authWithoutPassword, auth)
+}
+
+// ValidateExpiryPolicy returns an error when the expiry policy has a relative expiry for an auth type where PgFga
+// never sets a password
+func (fgc FgaGeneralConfig) ValidateExpiryPolicy() error {
+	for _, auth := range slices.Sorted(maps.Keys(fgc.ExpiryPolicy)) {
+		if fgc.ExpiryPolicy[auth].relativeWithoutPassword(auth) {
+			return fmt.Errorf("invalid expiry_policy for auth %s: a relative expiry is only renewed with a new password, "+
+				"which pgfga never sets for %s users", auth, auth)
+		}
+	}
+	return nil
+}
+
+// ValidateUserExpiry returns an error when a user has a relative expiry and an auth type where PgFga never sets a
+// password. The expiry of the role profile is merged in first, so that an expiry inherited from the profile is
+// validated as well.
+func (fc FgaConfig) ValidateUserExpiry() error {
+	for _, userName := range slices.Sorted(maps.Keys(fc.UserConfig)) {
+		userConfig := fc.UserConfig[userName]
+		profile, err := fc.RoleProfile(userConfig.Profile)
+		if err != nil {
+			return fmt.Errorf("invalid profile for user %s: %w", userName, err)
+		}
+		if userConfig.WithProfile(profile).Expiry.relativeWithoutPassword(userConfig.Auth) {
+			return fmt.Errorf("invalid expiry for user %s: a relative expiry is only renewed with a new password, "+
+				"which pgfga never sets for %s users", userName, userConfig.Auth)
+		}
+	}
+	return nil
+}
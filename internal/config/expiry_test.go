@@ -0,0 +1,51 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pgvillage-tools/pgfga/internal/config"
+	"github.com/pgvillage-tools/pgfga/pkg/duration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestExpiryYAML(t *testing.T) {
+	var parsed map[string]config.Expiry
+	require.NoError(t, yaml.Unmarshal([]byte("absolute: 2030-01-01\nrelative: 90d\n"), &parsed))
+	assert.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), parsed["absolute"].At)
+	assert.Zero(t, parsed["absolute"].After)
+	assert.Equal(t, 90*24*time.Hour, parsed["relative"].After.Duration())
+	assert.True(t, parsed["relative"].At.IsZero())
+	assert.True(t, parsed["unset"].IsZero())
+	assert.Error(t, yaml.Unmarshal([]byte("invalid: never"), &parsed))
+}
+
+func TestValidateExpiryPolicy(t *testing.T) {
+	assert.NoError(t, config.FgaGeneralConfig{ExpiryPolicy: map[string]config.Expiry{
+		"password":  {After: duration.Duration(90 * 24 * time.Hour)},
+		"ldap-user": {At: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}}.ValidateExpiryPolicy())
+	assert.Error(t, config.FgaGeneralConfig{ExpiryPolicy: map[string]config.Expiry{
+		"clientcert": {After: duration.Duration(90 * 24 * time.Hour)},
+	}}.ValidateExpiryPolicy())
+}
+
+func TestValidateUserExpiry(t *testing.T) {
+	relative := config.Expiry{After: duration.Duration(90 * 24 * time.Hour)}
+	cnf := config.FgaConfig{
+		RoleProfiles: map[string]config.RoleProfile{"rotated": {Expiry: relative}},
+		UserConfig: map[string]config.FgaUserConfig{
+			"app":    {Auth: "password", Profile: "rotated"},
+			"ldapie": {Auth: "ldap-user", Expiry: config.Expiry{At: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		},
+	}
+	assert.NoError(t, cnf.ValidateUserExpiry())
+
+	cnf.UserConfig["ldapie"] = config.FgaUserConfig{Auth: "ldap-user", Expiry: relative}
+	assert.ErrorContains(t, cnf.ValidateUserExpiry(), "invalid expiry for user ldapie")
+
+	cnf.UserConfig["ldapie"] = config.FgaUserConfig{Auth: "clientcert", Profile: "rotated"}
+	assert.ErrorContains(t, cnf.ValidateUserExpiry(), "invalid expiry for user ldapie")
+}
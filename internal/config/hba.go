@@ -0,0 +1,20 @@
+package config
+
+// HbaConfig defines if and how pgfga generates pg_hba.conf and pg_ident.conf from the users in the config
+type HbaConfig struct {
+	// Path is where pg_hba.conf is written (pg_hba.conf is not generated when empty)
+	Path string `yaml:"path"`
+	// IdentPath is where pg_ident.conf is written, for the certificate names of users with auth: clientcert
+	IdentPath string `yaml:"ident_path"`
+	// Template is a file with fixed lines for pg_hba.conf. The line "# pgfga rules" is replaced by the generated
+	// rules, which are added at the end when the template has no such line.
+	Template string `yaml:"template"`
+	// Addresses are the client addresses the rules apply to (defaults to all)
+	Addresses []string `yaml:"addresses"`
+	// Databases are the databases the rules apply to (defaults to the databases in the config)
+	Databases []string `yaml:"databases"`
+	// LdapOptions are the options for users with auth ldap-user and ldap-group (e.a. ldapserver=... ldapbasedn=...)
+	LdapOptions string `yaml:"ldap_options"`
+	// IdentMap is the name of the map in pg_ident.conf for users with auth: clientcert (defaults to pgfga)
+	IdentMap string `yaml:"ident_map"`
+}
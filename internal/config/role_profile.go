@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/pgvillage-tools/pgfga/pkg/pg"
+)
+
+// RoleProfile holds options, memberships and attributes that users and roles can inherit with profile
+type RoleProfile struct {
+	Options         []string        `yaml:"options"`
+	MemberOf        []pg.Membership `yaml:"memberof"`
+	Settings        pg.Settings     `yaml:"settings"`
+	Expiry          Expiry          `yaml:"expiry"`
+	ConnectionLimit *int            `yaml:"connection_limit"`
+}
+
+// RoleProfile returns the role profile with the specified name. An empty name returns an empty profile.
+func (fc FgaConfig) RoleProfile(name string) (profile RoleProfile, err error) {
+	if name == "" {
+		return profile, nil
+	}
+	profile, exists := fc.RoleProfiles[name]
+	if !exists {
+		return profile, fmt.Errorf("role profile %s is not defined in role_profiles", name)
+	}
+	return profile, nil
+}
+
+// ValidateRoleProfiles returns an error if a user or role uses a role profile that is not defined
+func (fc FgaConfig) ValidateRoleProfiles() error {
+	for _, userName := range slices.Sorted(maps.Keys(fc.UserConfig)) {
+		if _, err := fc.RoleProfile(fc.UserConfig[userName].Profile); err != nil {
+			return fmt.Errorf("invalid profile for user %s: %w", userName, err)
+		}
+	}
+	for _, roleName := range slices.Sorted(maps.Keys(fc.Roles)) {
+		if _, err := fc.RoleProfile(fc.Roles[roleName].Profile); err != nil {
+			return fmt.Errorf("invalid profile for role %s: %w", roleName, err)
+		}
+	}
+	return nil
+}
+
+// merge returns the settings, memberships, expiry and connection limit of the profile with the values of the user or
+// role on top. A membership of the user or role replaces the membership of the profile for the same role.
+// Options are merged by the handler (as a RoleOptionMap).
+func (rp RoleProfile) merge(
+	memberOf []pg.Membership,
+	settings pg.Settings,
+	expiry Expiry,
+	connectionLimit *int,
+) ([]pg.Membership, pg.Settings, Expiry, *int) {
+	memberOf = append(slices.DeleteFunc(slices.Clone(rp.MemberOf), func(profileMembership pg.Membership) bool {
+		return slices.ContainsFunc(memberOf, func(membership pg.Membership) bool {
+			return membership.Role == profileMembership.Role
+		})
+	}), memberOf...)
+	if rp.Settings != nil {
+		merged := maps.Clone(rp.Settings)
+		maps.Copy(merged, settings)
+		settings = merged
+	}
+	if expiry.IsZero() {
+		expiry = rp.Expiry
+	}
+	if connectionLimit == nil {
+		connectionLimit = rp.ConnectionLimit
+	}
+	return memberOf, settings, expiry, connectionLimit
+}
+
+// WithProfile returns the user config with the memberships, settings, expiry and connection limit of the profile
+// merged in
+func (uc FgaUserConfig) WithProfile(profile RoleProfile) FgaUserConfig {
+	uc.MemberOf, uc.Settings, uc.Expiry, uc.ConnectionLimit = profile.merge(
+		uc.MemberOf, uc.Settings, uc.Expiry, uc.ConnectionLimit)
+	return uc
+}
+
+// WithProfile returns the role config with the memberships, settings, expiry and connection limit of the profile
+// merged in
+func (rc FgaRoleConfig) WithProfile(profile RoleProfile) FgaRoleConfig {
+	rc.MemberOf, rc.Settings, rc.Expiry, rc.ConnectionLimit = profile.merge(
+		rc.MemberOf, rc.Settings, rc.Expiry, rc.ConnectionLimit)
+	return rc
+}
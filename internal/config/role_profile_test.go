@@ -0,0 +1,64 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/pgvillage-tools/pgfga/internal/config"
+	"github.com/pgvillage-tools/pgfga/pkg/pg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const roleProfilesYaml = `
+role_profiles:
+  app:
+    options: [LOGIN, NOINHERIT]
+    memberof: [readers]
+    settings:
+      statement_timeout: 30s
+      work_mem: 4MB
+    expiry: 90d
+    connection_limit: 10
+users:
+  appuser:
+    auth: password
+    profile: app
+    memberof:
+    - writers
+    - role: readers
+      admin: true
+    settings:
+      statement_timeout: 5s
+    connection_limit: 2
+roles:
+  approle:
+    profile: app
+`
+
+func TestRoleProfiles(t *testing.T) {
+	var cnf config.FgaConfig
+	require.NoError(t, yaml.Unmarshal([]byte(roleProfilesYaml), &cnf))
+	require.NoError(t, cnf.ValidateRoleProfiles())
+
+	profile, err := cnf.RoleProfile(cnf.UserConfig["appuser"].Profile)
+	require.NoError(t, err)
+	user := cnf.UserConfig["appuser"].WithProfile(profile)
+	admin := true
+	assert.Equal(t, []pg.Membership{{Role: "writers"}, {Role: "readers", Admin: &admin}}, user.MemberOf)
+	assert.Equal(t, pg.Settings{"statement_timeout": "5s", "work_mem": "4MB"}, user.Settings)
+	assert.Equal(t, 2, *user.ConnectionLimit)
+	assert.Equal(t, profile.Expiry, user.Expiry)
+
+	role := cnf.Roles["approle"].WithProfile(profile)
+	assert.Equal(t, []pg.Membership{{Role: "readers"}}, role.MemberOf)
+	assert.Equal(t, 10, *role.ConnectionLimit)
+	assert.Len(t, profile.Settings, 2, "profile should not be changed by merging")
+}
+
+func TestValidateRoleProfiles(t *testing.T) {
+	cnf := config.FgaConfig{Roles: map[string]config.FgaRoleConfig{"approle": {Profile: "unknown"}}}
+	assert.ErrorContains(t, cnf.ValidateRoleProfiles(), "invalid profile for role approle")
+	_, err := cnf.RoleProfile("")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pgvillage-tools/pgfga/internal/config"
+	"github.com/pgvillage-tools/pgfga/pkg/credential"
+	"github.com/pgvillage-tools/pgfga/pkg/pg"
+	"github.com/pgvillage-tools/pgfga/pkg/secret"
+)
+
+// generatedUserNames returns the login users for a user with auth: generated.
+// With keep_previous, two login users (<name>_1 and <name>_2) take turns and <name> is the role they are a member of.
+func generatedUserNames(userName string, keepPrevious bool) []string {
+	if !keepPrevious {
+		return []string{userName}
+	}
+	return []string{userName + "_1", userName + "_2"}
+}
+
+// nextGeneratedSecret returns the secret that should be used for a user with auth: generated, and whether it was
+// (re)generated. A new password is generated when there is no valid secret yet, or when it is older than rotate_after
+// (a secret without the moment it was generated is considered older).
+// With keep_previous, the new password is set for the other login user, and the current account becomes the previous.
+func nextGeneratedSecret(
+	userName string,
+	userConfig config.FgaUserConfig,
+	current secret.Secret,
+	exists bool,
+	now time.Time,
+) (next secret.Secret, rotated bool, err error) {
+	users := generatedUserNames(userName, userConfig.KeepPrevious)
+	if exists && current.Active.User == "" {
+		current.Active.User = userName
+	}
+	valid := exists && current.Active.Password != "" && slices.Contains(users, current.Active.User)
+	rotateAfter := userConfig.RotateAfter.Duration()
+	expired := rotateAfter > 0 && now.Sub(current.ChangedAt) > rotateAfter
+	if valid && !expired {
+		if !slices.Contains(users, current.Previous.User) || current.Previous.User == current.Active.User {
+			current.Previous = secret.Account{}
+		}
+		return current, false, nil
+	}
+	password, err := secret.GeneratePassword(userConfig.PasswordLength)
+	if err != nil {
+		return next, false, err
+	}
+	next.Active = secret.Account{User: users[0], Password: password}
+	next.ChangedAt = now
+	if userConfig.KeepPrevious && valid {
+		if current.Active.User == users[0] {
+			next.Active.User = users[1]
+		}
+		next.Previous = current.Active
+	}
+	return next, true, nil
+}
+
+func (pfh *PgFgaHandler) handleGeneratedUser(
+	userConfig config.FgaUserConfig,
+	userName string,
+	options pg.RoleOptionMap,
+) (err error) {
+	if err = userConfig.Output.Validate(); err != nil {
+		return fmt.Errorf("invalid output for user %s: %w", userName, err)
+	}
+	if userConfig.KeepPrevious && !userConfig.Output.SupportsUser() {
+		return errors.New("keep_previous requires an output format that holds the username (pgpass or kubernetes)")
+	}
+	if userConfig.RotateAfter > 0 && !userConfig.Output.SupportsChangedAt() {
+		return errors.New("rotate_after requires an output format that holds the rotation time (pgpass or kubernetes)")
+	}
+	if userConfig.State != pg.Present {
+		roleConfig := pfh.userRoleConfig(userConfig)
+		for _, loginName := range append(generatedUserNames(userName, userConfig.KeepPrevious), userName) {
+			pfh.pg.Roles.AddRole(pfh.applyRoleConfig(pfh.pg.GetRole(loginName), roleConfig, options))
+		}
+		return nil
+	}
+	encryption, err := pfh.passwordEncryption(userConfig.Auth)
+	if err != nil {
+		return err
+	}
+	current, exists, err := userConfig.Output.Read()
+	if err != nil {
+		return err
+	}
+	next, rotated, err := nextGeneratedSecret(userName, userConfig, current, exists, time.Now())
+	if err != nil {
+		return err
+	}
+	if rotated {
+		if err = userConfig.Output.Write(next); err != nil {
+			return err
+		}
+		log.Infof("Generated new password for user '%s' in '%s'", next.Active.User, userConfig.Output.Path)
+	}
+	roleConfig := pfh.userRoleConfig(userConfig)
+	loginOptions := options.Clone().AddAbsolute(pg.RoleLogin)
+	for _, account := range []secret.Account{next.Active, next.Previous} {
+		if account.User == "" {
+			continue
+		}
+		user := pfh.applyRoleConfig(pfh.pg.GetRole(account.User), roleConfig, loginOptions)
+		user.Password = credential.Credential{Value: account.Password}
+		user.PasswordEncryption = encryption
+		if account.User != userName {
+			// previous names only apply to the user itself, not to the extra user for keep_previous
+			user.PreviousNames = nil
+		}
+		pfh.pg.Roles.AddRole(user)
+		if userConfig.KeepPrevious {
+			pfh.pg.Grant(account.User, userName)
+		}
+	}
+	if userConfig.KeepPrevious {
+		group := pfh.applyRoleConfig(pfh.pg.GetRole(userName), groupRoleConfig(roleConfig), options)
+		pfh.pg.Roles.AddRole(group)
+	}
+	for _, granted := range userConfig.MemberOf {
+		pfh.pg.GrantMembership(userName, granted)
+	}
+	return nil
+}
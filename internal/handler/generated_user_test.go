@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pgvillage-tools/pgfga/internal/config"
+	"github.com/pgvillage-tools/pgfga/pkg/duration"
+	"github.com/pgvillage-tools/pgfga/pkg/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextGeneratedSecret(t *testing.T) {
+	const userName = "app"
+	now := time.Now()
+	rotateAfter := duration.Duration(24 * time.Hour)
+	userConfig := config.FgaUserConfig{RotateAfter: rotateAfter}
+
+	next, rotated, err := nextGeneratedSecret(userName, userConfig, secret.Secret{}, false, now)
+	require.NoError(t, err)
+	assert.True(t, rotated)
+	assert.Equal(t, userName, next.Active.User)
+	assert.Len(t, next.Active.Password, secret.DefaultPasswordLength)
+	assert.Equal(t, now, next.ChangedAt)
+
+	current := secret.Secret{Active: secret.Account{Password: "pass"}, ChangedAt: now.Add(-time.Hour)}
+	next, rotated, err = nextGeneratedSecret(userName, userConfig, current, true, now)
+	require.NoError(t, err)
+	assert.False(t, rotated)
+	assert.Equal(t, secret.Account{User: userName, Password: "pass"}, next.Active)
+
+	current.ChangedAt = now.Add(-48 * time.Hour)
+	next, rotated, err = nextGeneratedSecret(userName, userConfig, current, true, now)
+	require.NoError(t, err)
+	assert.True(t, rotated)
+	assert.NotEqual(t, "pass", next.Active.Password)
+	assert.Empty(t, next.Previous.User)
+
+	current.ChangedAt = time.Time{}
+	_, rotated, err = nextGeneratedSecret(userName, userConfig, current, true, now)
+	require.NoError(t, err)
+	assert.True(t, rotated)
+}
+
+func TestNextGeneratedSecretKeepPrevious(t *testing.T) {
+	const userName = "app"
+	now := time.Now()
+	userConfig := config.FgaUserConfig{RotateAfter: duration.Duration(24 * time.Hour), KeepPrevious: true}
+
+	next, rotated, err := nextGeneratedSecret(userName, userConfig, secret.Secret{}, false, now)
+	require.NoError(t, err)
+	assert.True(t, rotated)
+	assert.Equal(t, "app_1", next.Active.User)
+	assert.Empty(t, next.Previous.User)
+
+	next.ChangedAt = now.Add(-48 * time.Hour)
+	rotatedSecret, rotated, err := nextGeneratedSecret(userName, userConfig, next, true, now)
+	require.NoError(t, err)
+	assert.True(t, rotated)
+	assert.Equal(t, "app_2", rotatedSecret.Active.User)
+	assert.Equal(t, next.Active, rotatedSecret.Previous)
+
+	rotatedSecret.ChangedAt = now.Add(-48 * time.Hour)
+	rotatedAgain, rotated, err := nextGeneratedSecret(userName, userConfig, rotatedSecret, true, now)
+	require.NoError(t, err)
+	assert.True(t, rotated)
+	assert.Equal(t, "app_1", rotatedAgain.Active.User)
+	assert.Equal(t, rotatedSecret.Active, rotatedAgain.Previous)
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pgvillage-tools/pgfga/internal/config"
+	"github.com/pgvillage-tools/pgfga/pkg/credential"
+	"github.com/pgvillage-tools/pgfga/pkg/pg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const connectTimeout = 2 * time.Second
+
+// testConn connects to the PostgreSQL server from the libpq environment (PGHOST, PGUSER, ...). The test is skipped
+// when PGHOST is not set, and fails when the server is not available.
+func testConn(t *testing.T) *pgx.Conn {
+	t.Helper()
+	if os.Getenv("PGHOST") == "" {
+		t.Skip("PGHOST is not set, skipping test against PostgreSQL")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	conn, err := pgx.Connect(ctx, "")
+	require.NoError(t, err, "PostgreSQL is not available")
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+	if log == nil {
+		Initialize()
+	}
+	return conn
+}
+
+// handle runs Handle for a config, against the PostgreSQL server from the libpq environment
+func handle(t *testing.T, cnf config.FgaConfig) {
+	t.Helper()
+	pfh := PgFgaHandler{
+		config: cnf,
+		pg: pg.NewPgHandler(pg.ConnParams{}, credential.Credential{}, cnf.StrictConfig, cnf.Tablespaces,
+			cnf.DbsConfig, cnf.Slots),
+	}
+	require.NoError(t, pfh.Handle())
+}
+
+// queryField returns the first field of the first row of a query (or an empty string when there is no row)
+func queryField(t *testing.T, conn *pgx.Conn, query string, args ...any) string {
+	t.Helper()
+	var field string
+	err := conn.QueryRow(context.Background(), query, args...).Scan(&field)
+	if err == pgx.ErrNoRows {
+		return ""
+	}
+	require.NoError(t, err)
+	return field
+}
+
+// rolesConfig returns a config with only one role
+func rolesConfig(name string, role config.FgaRoleConfig) config.FgaConfig {
+	return config.FgaConfig{Roles: map[string]config.FgaRoleConfig{name: role}}
+}
+
+func TestHandleCreatesRolesAndDatabases(t *testing.T) {
+	conn := testConn(t)
+	const (
+		roleName = "pgfga_handle_create"
+		dbName   = "pgfga_handle_create_db"
+	)
+	t.Cleanup(func() {
+		for _, query := range []string{
+			"DROP DATABASE IF EXISTS " + dbName,
+			"DROP ROLE IF EXISTS " + roleName,
+			"DROP ROLE IF EXISTS " + dbName,
+			"DROP ROLE IF EXISTS " + dbName + "_readonly",
+			"DROP ROLE IF EXISTS " + dbName + "_readwrite",
+		} {
+			_, _ = conn.Exec(context.Background(), query)
+		}
+	})
+	cnf := rolesConfig(roleName, config.FgaRoleConfig{})
+	cnf.DbsConfig = pg.Databases{dbName: {}}
+	handle(t, cnf)
+	assert.Equal(t, roleName, queryField(t, conn, "SELECT rolname FROM pg_roles WHERE rolname = $1", roleName))
+	assert.Equal(t, dbName, queryField(t, conn,
+		"SELECT rolname FROM pg_database INNER JOIN pg_roles ON datdba = pg_roles.oid WHERE datname = $1", dbName))
+}
+
+func TestHandleDropsAbsentRoles(t *testing.T) {
+	conn := testConn(t)
+	const roleName = "pgfga_handle_drop"
+	handle(t, rolesConfig(roleName, config.FgaRoleConfig{}))
+	assert.Equal(t, roleName, queryField(t, conn, "SELECT rolname FROM pg_roles WHERE rolname = $1", roleName))
+
+	handle(t, rolesConfig(roleName, config.FgaRoleConfig{State: pg.Absent}))
+	assert.Empty(t, queryField(t, conn, "SELECT rolname FROM pg_roles WHERE rolname = $1", roleName))
+}
+
+func TestHandleKeepsRolesThatAreNotInTheConfig(t *testing.T) {
+	conn := testConn(t)
+	const roleName = "pgfga_handle_keep"
+	t.Cleanup(func() {
+		_, _ = conn.Exec(context.Background(), "DROP ROLE IF EXISTS "+roleName)
+	})
+	handle(t, rolesConfig(roleName, config.FgaRoleConfig{}))
+
+	handle(t, config.FgaConfig{})
+	assert.Equal(t, roleName, queryField(t, conn, "SELECT rolname FROM pg_roles WHERE rolname = $1", roleName))
+}
+
+func TestHandleDropsAbsentDatabases(t *testing.T) {
+	conn := testConn(t)
+	const dbName = "pgfga_handle_drop_db"
+	t.Cleanup(func() {
+		for _, query := range []string{
+			"DROP DATABASE IF EXISTS " + dbName,
+			"DROP ROLE IF EXISTS " + dbName,
+			"DROP ROLE IF EXISTS " + dbName + "_readonly",
+			"DROP ROLE IF EXISTS " + dbName + "_readwrite",
+		} {
+			_, _ = conn.Exec(context.Background(), query)
+		}
+	})
+	handle(t, config.FgaConfig{DbsConfig: pg.Databases{dbName: {}}})
+	assert.Equal(t, dbName, queryField(t, conn, "SELECT datname FROM pg_database WHERE datname = $1", dbName))
+
+	handle(t, config.FgaConfig{DbsConfig: pg.Databases{dbName: {State: pg.Absent}}})
+	assert.Empty(t, queryField(t, conn, "SELECT datname FROM pg_database WHERE datname = $1", dbName))
+}
+
+func TestHandleSoftDeletesAbsentRoles(t *testing.T) {
+	conn := testConn(t)
+	const roleName = "pgfga_handle_soft_delete"
+	deletedName := roleName + "_deleted_" + time.Now().UTC().Format("20060102")
+	t.Cleanup(func() {
+		_, _ = conn.Exec(context.Background(), "DROP ROLE IF EXISTS "+deletedName)
+	})
+	handle(t, rolesConfig(roleName, config.FgaRoleConfig{}))
+
+	handle(t, rolesConfig(roleName, config.FgaRoleConfig{
+		State:      pg.Absent,
+		SoftDelete: pg.SoftDelete{Enabled: true},
+	}))
+	assert.Empty(t, queryField(t, conn, "SELECT rolname FROM pg_roles WHERE rolname = $1", roleName))
+	assert.Contains(t, queryField(t, conn,
+		"SELECT shobj_description(oid, 'pg_authid') FROM pg_roles WHERE rolname = $1", deletedName),
+		"pgfga: soft deleted at ")
+
+	handle(t, rolesConfig(roleName, config.FgaRoleConfig{}))
+	assert.Equal(t, roleName, queryField(t, conn, "SELECT rolname FROM pg_roles WHERE rolname = $1", roleName))
+	assert.Empty(t, queryField(t, conn, "SELECT rolname FROM pg_roles WHERE rolname = $1", deletedName))
+	handle(t, rolesConfig(roleName, config.FgaRoleConfig{State: pg.Absent}))
+}
+
+func TestHandleReassignsObjectsOfDroppedRoles(t *testing.T) {
+	conn := testConn(t)
+	const (
+		roleName   = "pgfga_handle_reassign"
+		targetName = "pgfga_handle_reassign_target"
+		schemaName = "pgfga_handle_reassign"
+	)
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, _ = conn.Exec(ctx, "DROP SCHEMA IF EXISTS "+schemaName)
+		_, _ = conn.Exec(ctx, "DROP ROLE IF EXISTS "+targetName)
+	})
+	cnf := config.FgaConfig{Roles: map[string]config.FgaRoleConfig{roleName: {}, targetName: {}}}
+	handle(t, cnf)
+	_, err := conn.Exec(ctx, "CREATE SCHEMA "+schemaName+" AUTHORIZATION "+roleName)
+	require.NoError(t, err)
+
+	cnf.Roles[roleName] = config.FgaRoleConfig{State: pg.Absent, ReassignTo: targetName}
+	handle(t, cnf)
+	assert.Empty(t, queryField(t, conn, "SELECT rolname FROM pg_roles WHERE rolname = $1", roleName))
+	assert.Equal(t, targetName, queryField(t, conn,
+		"SELECT nspowner::regrole::text FROM pg_namespace WHERE nspname = $1", schemaName))
+}
+
+func TestHandleSetsPasswordOfRenamedMD5Users(t *testing.T) {
+	conn := testConn(t)
+	const (
+		oldName  = "pgfga_handle_rename_old"
+		newName  = "pgfga_handle_rename_new"
+		password = "pgfga_handle_rename"
+	)
+	userConfig := config.FgaUserConfig{Auth: "md5", Password: credential.Credential{Value: password}}
+	handle(t, config.FgaConfig{UserConfig: map[string]config.FgaUserConfig{oldName: userConfig}})
+
+	userConfig.PreviousNames = []string{oldName}
+	cnf := config.FgaConfig{UserConfig: map[string]config.FgaUserConfig{newName: userConfig}}
+	handle(t, cnf)
+	t.Cleanup(func() {
+		_, _ = conn.Exec(context.Background(), "DROP ROLE IF EXISTS "+newName)
+	})
+	assert.Empty(t, queryField(t, conn, "SELECT rolname FROM pg_roles WHERE rolname = $1", oldName))
+	assert.Equal(t, fmt.Sprintf("md5%x", md5.Sum([]byte(password+newName))), queryField(t, conn,
+		"SELECT COALESCE(rolpassword, '') FROM pg_authid WHERE rolname = $1", newName))
+}
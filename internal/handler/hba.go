@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/pgvillage-tools/pgfga/pkg/pg"
+)
+
+const (
+	defaultIdentMap = "pgfga"
+	hbaAll          = "all"
+)
+
+// handleHba generates pg_hba.conf and pg_ident.conf from the users in the config (when hba.path is set)
+func (pfh PgFgaHandler) handleHba() (err error) {
+	hbaConfig := pfh.config.Hba
+	if hbaConfig.Path == "" {
+		return nil
+	}
+	var template []byte
+	if hbaConfig.Template != "" {
+		if template, err = os.ReadFile(hbaConfig.Template); err != nil {
+			return err
+		}
+	}
+	rules, mappings, err := pfh.hbaRules()
+	if err != nil {
+		return err
+	}
+	if len(mappings) > 0 && hbaConfig.IdentPath == "" {
+		return errors.New("hba.ident_path must be set for users with auth: clientcert")
+	}
+	return pfh.pg.ApplyHba(
+		hbaConfig.Path, pg.RenderHba(string(template), rules),
+		hbaConfig.IdentPath, pg.RenderIdent(mappings))
+}
+
+// hbaDatabases returns the databases for the rules: hba.databases, or else all databases in the config
+func (pfh PgFgaHandler) hbaDatabases() []string {
+	if len(pfh.config.Hba.Databases) > 0 {
+		return pfh.config.Hba.Databases
+	}
+	var databases []string
+	for _, dbName := range slices.Sorted(maps.Keys(pfh.config.DbsConfig)) {
+		if pfh.config.DbsConfig[dbName].State == pg.Present {
+			databases = append(databases, dbName)
+		}
+	}
+	if len(databases) == 0 {
+		return []string{hbaAll}
+	}
+	return databases
+}
+
+// hbaRules returns the pg_hba.conf rules and pg_ident.conf mappings for all users with state Present
+func (pfh PgFgaHandler) hbaRules() (rules []pg.HbaRule, mappings []pg.IdentMapping, err error) {
+	hbaConfig := pfh.config.Hba
+	addresses := hbaConfig.Addresses
+	if len(addresses) == 0 {
+		addresses = []string{hbaAll}
+	}
+	identMap := hbaConfig.IdentMap
+	if identMap == "" {
+		identMap = defaultIdentMap
+	}
+	databases := pfh.hbaDatabases()
+	for _, userName := range slices.Sorted(maps.Keys(pfh.config.UserConfig)) {
+		userConfig := pfh.config.UserConfig[userName]
+		if userConfig.State != pg.Present {
+			continue
+		}
+		rule := pg.HbaRule{Type: "host", Databases: databases, Users: []string{userName}}
+		switch userConfig.Auth {
+		case "ldap-group":
+			// Members of the group are matched on their membership of the group role
+			rule.Users = []string{"+" + userName}
+			rule.Method, rule.Options = "ldap", hbaConfig.LdapOptions
+		case "ldap-user":
+			rule.Method, rule.Options = "ldap", hbaConfig.LdapOptions
+		case "clientcert":
+			rule.Type, rule.Method, rule.Options = "hostssl", "cert", "map="+identMap
+			certNames := userConfig.CertNames
+			if len(certNames) == 0 {
+				certNames = []string{userName}
+			}
+			for _, certName := range certNames {
+				mappings = append(mappings, pg.IdentMapping{Map: identMap, SystemUser: certName, PgUser: userName})
+			}
+		case "password", "md5", "scram", "generated":
+			encryption, err := pfh.passwordEncryption(userConfig.Auth)
+			if err != nil {
+				return nil, nil, err
+			}
+			rule.Method = string(encryption)
+			if userConfig.Auth == "generated" && userConfig.KeepPrevious {
+				// Both login users are members of the role named after the user
+				rule.Users = []string{"+" + userName}
+			}
+		default:
+			return nil, nil, fmt.Errorf("invalid auth %s for user %s", userConfig.Auth, userName)
+		}
+		if rule.Method == "ldap" && hbaConfig.LdapOptions == "" {
+			return nil, nil, fmt.Errorf("hba.ldap_options must be set for user %s (auth: %s)",
+				userName, userConfig.Auth)
+		}
+		for _, address := range addresses {
+			rule.Address = address
+			rules = append(rules, rule)
+		}
+	}
+	return rules, mappings, nil
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/pgvillage-tools/pgfga/internal/config"
+	"github.com/pgvillage-tools/pgfga/pkg/pg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHbaRules(t *testing.T) {
+	pfh := PgFgaHandler{config: config.FgaConfig{
+		GeneralConfig: config.FgaGeneralConfig{PasswordEncryption: pg.PasswordEncryptionScram},
+		Hba: config.HbaConfig{
+			Addresses:   []string{"10.0.0.0/8"},
+			LdapOptions: "ldapserver=ldap.example.com",
+		},
+		DbsConfig: pg.Databases{"appdb": pg.Database{}, "olddb": pg.Database{State: pg.Absent}},
+		UserConfig: map[string]config.FgaUserConfig{
+			"admins":   {Auth: "ldap-group"},
+			"app":      {Auth: "password"},
+			"batch":    {Auth: "generated", KeepPrevious: true},
+			"monitor":  {Auth: "clientcert", CertNames: []string{"monitor.example.com"}},
+			"removed":  {Auth: "md5", State: pg.Absent},
+			"readonly": {Auth: "md5"},
+		},
+	}}
+	rules, mappings, err := pfh.hbaRules()
+	require.NoError(t, err)
+	var lines []string
+	for _, rule := range rules {
+		lines = append(lines, rule.String())
+	}
+	assert.Equal(t, []string{
+		"host\tappdb\t+admins\t10.0.0.0/8\tldap\tldapserver=ldap.example.com",
+		"host\tappdb\tapp\t10.0.0.0/8\tscram-sha-256",
+		"host\tappdb\t+batch\t10.0.0.0/8\tscram-sha-256",
+		"hostssl\tappdb\tmonitor\t10.0.0.0/8\tcert\tmap=pgfga",
+		"host\tappdb\treadonly\t10.0.0.0/8\tmd5",
+	}, lines)
+	assert.Equal(t, []pg.IdentMapping{{Map: "pgfga", SystemUser: "monitor.example.com", PgUser: "monitor"}}, mappings)
+}
+
+func TestHbaRulesRequireLdapOptions(t *testing.T) {
+	pfh := PgFgaHandler{config: config.FgaConfig{
+		UserConfig: map[string]config.FgaUserConfig{"ldapuser": {Auth: "ldap-user"}},
+	}}
+	_, _, err := pfh.hbaRules()
+	assert.ErrorContains(t, err, "hba.ldap_options")
+}
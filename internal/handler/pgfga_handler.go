@@ -51,16 +51,36 @@ func NewPgFgaHandler() (pfh *PgFgaHandler, err error) {
 
 	atom.SetLevel(cnf.GeneralConfig.LogLevel)
 
+	if err = cnf.ValidateRoleProfiles(); err != nil {
+		return pfh, err
+	}
+	if err = cnf.GeneralConfig.ValidateExpiryPolicy(); err != nil {
+		return pfh, err
+	}
+	if err = cnf.ValidateUserExpiry(); err != nil {
+		return pfh, err
+	}
+	// handleDatabases and handleDbRoles use the databases from the config, so profiles are applied in the config
+	if cnf.DbsConfig, err = cnf.DbsConfig.ApplyProfiles(cnf.DatabaseProfiles, cnf.DefaultDatabaseProfile); err != nil {
+		return pfh, err
+	}
+
 	pfh = &PgFgaHandler{}
 	pfh.config = cnf
 	pfh.ldap = ldap.NewLdapHandler(cnf.LdapConfig)
-	pfh.pg = pg.NewPgHandler(cnf.PgDsn, cnf.StrictConfig, cnf.DbsConfig, cnf.Slots)
+	pfh.pg = pg.NewPgHandler(cnf.PgDsn.Params, cnf.PgDsn.Password, cnf.StrictConfig, cnf.Tablespaces, cnf.DbsConfig,
+		cnf.Slots)
+	pfh.pg.SlotGuardrails = cnf.SlotGuardrails
+	pfh.pg.ServerSettings = cnf.ServerSettings
 
 	return pfh, nil
 }
 
 // Handle will do all the heavy lifting of handling a PgFga run
 func (pfh PgFgaHandler) Handle() error {
+	if len(pfh.config.Command) > 0 {
+		return pfh.handleCommand(pfh.config.Command)
+	}
 	time.Sleep(pfh.config.GeneralConfig.RunDelay)
 
 	for _, subHandler := range []func() error{
@@ -74,7 +94,15 @@ func (pfh PgFgaHandler) Handle() error {
 			log.Fatal(err)
 		}
 	}
-	return pfh.pg.Reconcile()
+	if err := pfh.pg.Reconcile(); err != nil {
+		return err
+	}
+	// Objects with state Absent are dropped after all other objects are reconciled, so that e.a. the objects of a
+	// dropped role can be reassigned to a role that is created in the same run
+	if err := pfh.pg.Finalize(); err != nil {
+		return err
+	}
+	return pfh.handleHba()
 }
 
 func (pfh *PgFgaHandler) handleLdapGroup(
@@ -90,22 +118,18 @@ func (pfh *PgFgaHandler) handleLdapGroup(
 	if err != nil {
 		return err
 	}
-	group := pg.Role{
-		Name:    baseGroup.Name(),
-		Options: options,
-		State:   userConfig.State,
-	}
+	roleConfig := pfh.userRoleConfig(userConfig)
+	group := pfh.applyRoleConfig(pg.Role{Name: baseGroup.Name()}, groupRoleConfig(roleConfig), options)
 	pfh.pg.Roles.AddRole(group)
 	if userConfig.State == pg.Present {
 		for _, granted := range userConfig.MemberOf {
-			pfh.pg.Grant(groupName, granted)
+			pfh.pg.GrantMembership(groupName, granted)
 		}
 	}
 	userOptions := options.Clone().AddAbsolute(pg.RoleLogin)
 	for _, ms := range baseGroup.MembershipTree() {
-		user := pfh.pg.GetRole(ms.GetMember().Name())
-		user.Options = userOptions
-		user.State = userConfig.State
+		user := pfh.applyRoleConfig(pfh.pg.GetRole(ms.GetMember().Name()), roleConfig, userOptions)
+		user.PreviousNames = userConfig.MemberPreviousNames[user.Name]
 		pfh.pg.Roles.AddRole(user)
 		pfh.pg.Grants = append(pfh.pg.Grants,
 			pg.Grant{Grantee: user, Granted: group, State: pg.Present},
@@ -121,33 +145,149 @@ func (pfh *PgFgaHandler) handleLdapUser(
 ) (err error) {
 	log.Debugf("Configuring user %s with %s", userName, userConfig.Auth)
 	options = options.AddAbsolute(pg.RoleLogin)
-	user := pfh.pg.GetRole(userName)
-	user.Options = options
-	user.State = userConfig.State
+	user := pfh.applyRoleConfig(pfh.pg.GetRole(userName), pfh.userRoleConfig(userConfig), options)
 	pfh.pg.Roles.AddRole(user)
 	if userConfig.State == pg.Present {
 		for _, granted := range userConfig.MemberOf {
-			pfh.pg.Grant(userName, granted)
+			pfh.pg.GrantMembership(userName, granted)
 		}
 	}
 	return nil
 }
 
+// roleOptions validates and converts a list of option names to a RoleOptionMap
+func roleOptions(optionNames []string) (options pg.RoleOptionMap, err error) {
+	options = pg.RoleOptionMap{}
+	for _, optionName := range optionNames {
+		option := pg.RoleOption(optionName)
+		if err = option.Validate(); err != nil {
+			return nil, err
+		}
+		options = options.AddAbsolute(option)
+	}
+	return options, nil
+}
+
+// profileRoleOptions returns the options of a role profile, merged with the options of the user or role itself
+func profileRoleOptions(profile config.RoleProfile, optionNames []string) (options pg.RoleOptionMap, err error) {
+	profileOptions, err := roleOptions(profile.Options)
+	if err != nil {
+		return nil, err
+	}
+	options, err = roleOptions(optionNames)
+	if err != nil {
+		return nil, err
+	}
+	return profileOptions.Merge(options), nil
+}
+
+// userExpiry returns the expiry for a user. When the user has no expiry set, the expiry policy for the auth type of
+// the user is used.
+func (pfh *PgFgaHandler) userExpiry(userConfig config.FgaUserConfig) config.Expiry {
+	if userConfig.Expiry.IsZero() {
+		return pfh.config.GeneralConfig.ExpiryPolicy[userConfig.Auth]
+	}
+	return userConfig.Expiry
+}
+
+// userRoleConfig returns the attributes that a user shares with roles, with the expiry policy applied
+func (pfh *PgFgaHandler) userRoleConfig(userConfig config.FgaUserConfig) config.FgaRoleConfig {
+	return config.FgaRoleConfig{
+		PreviousNames:            userConfig.PreviousNames,
+		ReassignTo:               userConfig.ReassignTo,
+		Force:                    userConfig.Force,
+		SoftDelete:               userConfig.SoftDelete,
+		AuthoritativeOptions:     userConfig.AuthoritativeOptions,
+		AuthoritativeMemberships: userConfig.AuthoritativeMemberships,
+		Expiry:                   pfh.userExpiry(userConfig),
+		ConnectionLimit:          userConfig.ConnectionLimit,
+		Settings:                 userConfig.Settings,
+		State:                    userConfig.State,
+	}
+}
+
+// groupRoleConfig returns the attributes for the group role of an ldap group (or a generated user with
+// keep_previous). Expiry and connection limit only apply to the users that log in, not to the group itself.
+func groupRoleConfig(roleConfig config.FgaRoleConfig) config.FgaRoleConfig {
+	roleConfig.Expiry = config.Expiry{}
+	roleConfig.ConnectionLimit = nil
+	return roleConfig
+}
+
+// applyRoleConfig sets the options and all attributes that users and roles share on a role
+func (pfh *PgFgaHandler) applyRoleConfig(
+	role pg.Role,
+	roleConfig config.FgaRoleConfig,
+	options pg.RoleOptionMap,
+) pg.Role {
+	role.Options = options
+	role.Settings = roleConfig.Settings
+	role.Expiry, role.ExpiryAfter = roleConfig.Expiry.At, roleConfig.Expiry.After.Duration()
+	role.ConnectionLimit = roleConfig.ConnectionLimit
+	role.State = roleConfig.State
+	role.PreviousNames = roleConfig.PreviousNames
+	role.ReassignTo = roleConfig.ReassignTo
+	role.Force = roleConfig.Force
+	role.SoftDelete = roleConfig.SoftDelete
+	role.AuthoritativeOptions = pfh.authoritativeOptions(roleConfig.AuthoritativeOptions)
+	role.AuthoritativeMemberships = pfh.authoritativeMemberships(roleConfig.AuthoritativeMemberships)
+	role.Adopt = true
+	return role
+}
+
+// authoritativeOptions returns if all options of a user or role that are not defined should be reset to their
+// default. When not set for the user or role, strict.role_options is used.
+func (pfh *PgFgaHandler) authoritativeOptions(authoritative *bool) bool {
+	if authoritative == nil {
+		return pfh.config.StrictConfig.RoleOptions
+	}
+	return *authoritative
+}
+
+// authoritativeMemberships returns if all undeclared memberships of a user or role should be revoked.
+// When not set for the user or role, strict.memberships is used.
+func (pfh *PgFgaHandler) authoritativeMemberships(authoritative *bool) bool {
+	if authoritative == nil {
+		return pfh.config.StrictConfig.Memberships
+	}
+	return *authoritative
+}
+
+// passwordEncryption returns the algorithm to hash passwords with for a specific auth type.
+// md5 and scram set the algorithm, password follows the general config (defaulting to md5).
+func (pfh *PgFgaHandler) passwordEncryption(auth string) (encryption pg.PasswordEncryption, err error) {
+	switch auth {
+	case "md5":
+		return pg.PasswordEncryptionMD5, nil
+	case "scram":
+		return pg.PasswordEncryptionScram, nil
+	}
+	encryption = pfh.config.GeneralConfig.PasswordEncryption
+	if encryption == "" {
+		return pg.PasswordEncryptionMD5, nil
+	}
+	return encryption, encryption.Validate()
+}
+
 func (pfh *PgFgaHandler) handlePasswordUser(
 	userConfig config.FgaUserConfig,
 	userName string,
 	options pg.RoleOptionMap,
 ) (err error) {
+	encryption, err := pfh.passwordEncryption(userConfig.Auth)
+	if err != nil {
+		return err
+	}
 	options = options.AddAbsolute(pg.RoleLogin)
-	user := pfh.pg.GetRole(userName)
-	user.Options = options
-	user.State = userConfig.State
-	pfh.pg.Roles.AddRole(user)
+	user := pfh.applyRoleConfig(pfh.pg.GetRole(userName), pfh.userRoleConfig(userConfig), options)
 	if userConfig.State == pg.Present {
 		user.Password = userConfig.Password
-		user.Expiry = userConfig.Expiry
+		user.PasswordEncryption = encryption
+	}
+	pfh.pg.Roles.AddRole(user)
+	if userConfig.State == pg.Present {
 		for _, granted := range userConfig.MemberOf {
-			pfh.pg.Grant(userName, granted)
+			pfh.pg.GrantMembership(userName, granted)
 		}
 	}
 	return nil
@@ -155,13 +295,14 @@ func (pfh *PgFgaHandler) handlePasswordUser(
 
 func (pfh *PgFgaHandler) handleUsers() (err error) {
 	for userName, userConfig := range pfh.config.UserConfig {
-		options := pg.RoleOptionMap{}
-		for _, optionName := range userConfig.Options {
-			option := pg.RoleOption(optionName)
-			if err = option.Validate(); err != nil {
-				return err
-			}
-			options = options.AddAbsolute(option)
+		profile, err := pfh.config.RoleProfile(userConfig.Profile)
+		if err != nil {
+			return err
+		}
+		userConfig = userConfig.WithProfile(profile)
+		options, err := profileRoleOptions(profile, userConfig.Options)
+		if err != nil {
+			return err
 		}
 		switch userConfig.Auth {
 		case "ldap-group":
@@ -172,10 +313,14 @@ func (pfh *PgFgaHandler) handleUsers() (err error) {
 			if err = pfh.handleLdapUser(userConfig, userName, options); err != nil {
 				return err
 			}
-		case "password", "md5":
+		case "password", "md5", "scram":
 			if err = pfh.handlePasswordUser(userConfig, userName, options); err != nil {
 				return err
 			}
+		case "generated":
+			if err = pfh.handleGeneratedUser(userConfig, userName, options); err != nil {
+				return err
+			}
 		default:
 			log.Fatalf("Invalid auth %s for user %s", userConfig.Auth, userName)
 		}
@@ -185,23 +330,22 @@ func (pfh *PgFgaHandler) handleUsers() (err error) {
 
 func (pfh *PgFgaHandler) handleRoles() (err error) {
 	for roleName, roleConfig := range pfh.config.Roles {
-		options := pg.RoleOptionMap{}
-		for _, optionName := range roleConfig.Options {
-			option := pg.RoleOption(optionName)
-			if err = option.Validate(); err != nil {
-				return err
-			}
-			options[option] = option.Enabled()
+		profile, err := pfh.config.RoleProfile(roleConfig.Profile)
+		if err != nil {
+			return err
+		}
+		roleConfig = roleConfig.WithProfile(profile)
+		options, err := profileRoleOptions(profile, roleConfig.Options)
+		if err != nil {
+			return err
 		}
 
-		role := pfh.pg.GetRole(roleName)
-		role.Options = options
-		role.State = roleConfig.State
+		role := pfh.applyRoleConfig(pfh.pg.GetRole(roleName), roleConfig, options)
 		pfh.pg.Roles.AddRole(role)
 
 		if roleConfig.State == pg.Present {
 			for _, granted := range roleConfig.MemberOf {
-				pfh.pg.Grant(roleName, granted)
+				pfh.pg.GrantMembership(roleName, granted)
 			}
 		}
 	}
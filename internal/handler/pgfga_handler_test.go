@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pgvillage-tools/pgfga/internal/config"
+	"github.com/pgvillage-tools/pgfga/pkg/credential"
+	"github.com/pgvillage-tools/pgfga/pkg/duration"
+	"github.com/pgvillage-tools/pgfga/pkg/pg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileRoleOptions(t *testing.T) {
+	options, err := profileRoleOptions(
+		config.RoleProfile{Options: []string{"LOGIN", "CREATEDB"}},
+		[]string{"NOCREATEDB", "REPLICATION"})
+	require.NoError(t, err)
+	assert.Equal(t, pg.RoleOptionMap{pg.RoleLogin: true, pg.RoleCreateDB: false, pg.RoleReplication: true}, options)
+	_, err = profileRoleOptions(config.RoleProfile{Options: []string{"INVALID"}}, nil)
+	assert.Error(t, err)
+}
+
+func TestApplyRoleConfig(t *testing.T) {
+	limit := 5
+	authoritative := false
+	pfh := PgFgaHandler{config: config.FgaConfig{
+		GeneralConfig: config.FgaGeneralConfig{ExpiryPolicy: map[string]config.Expiry{
+			"md5": {After: duration.Duration(time.Hour)},
+		}},
+		StrictConfig: pg.StrictOptions{RoleOptions: true, Memberships: true},
+	}}
+	roleConfig := pfh.userRoleConfig(config.FgaUserConfig{
+		Auth:                 "md5",
+		PreviousNames:        []string{"old"},
+		ReassignTo:           "owner",
+		SoftDelete:           pg.SoftDelete{Enabled: true},
+		AuthoritativeOptions: &authoritative,
+		ConnectionLimit:      &limit,
+	})
+	options := pg.RoleOptionMap{pg.RoleLogin: true}
+	user := pfh.applyRoleConfig(pg.Role{Name: "app"}, roleConfig, options)
+	assert.Equal(t, pg.Role{
+		Name:                     "app",
+		Options:                  options,
+		ExpiryAfter:              time.Hour,
+		ConnectionLimit:          &limit,
+		PreviousNames:            []string{"old"},
+		ReassignTo:               "owner",
+		SoftDelete:               pg.SoftDelete{Enabled: true},
+		AuthoritativeMemberships: true,
+		Adopt:                    true,
+	}, user)
+
+	group := pfh.applyRoleConfig(pg.Role{Name: "app"}, groupRoleConfig(roleConfig), options)
+	assert.Zero(t, group.ExpiryAfter)
+	assert.Nil(t, group.ConnectionLimit)
+}
+
+func TestHandleRolesAndUsersKeepAbsentState(t *testing.T) {
+	cnf := config.FgaConfig{
+		Roles: map[string]config.FgaRoleConfig{
+			"dropped_role": {State: pg.Absent},
+			"member":       {MemberOf: []pg.Membership{{Role: "dropped_role"}}},
+		},
+		UserConfig: map[string]config.FgaUserConfig{
+			"dropped_user": {Auth: "md5", State: pg.Absent},
+		},
+	}
+	pfh := PgFgaHandler{
+		config: cnf,
+		pg:     pg.NewPgHandler(pg.ConnParams{}, credential.Credential{}, cnf.StrictConfig, nil, nil, nil),
+	}
+	require.NoError(t, pfh.handleRoles())
+	require.NoError(t, pfh.handleUsers())
+	assert.Equal(t, pg.Absent, pfh.pg.Roles["dropped_role"].State)
+	assert.Equal(t, pg.Absent, pfh.pg.Roles["dropped_user"].State)
+	assert.Equal(t, pg.Present, pfh.pg.Roles["member"].State)
+}
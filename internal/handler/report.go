@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pgvillage-tools/pgfga/pkg/duration"
+	"github.com/pgvillage-tools/pgfga/pkg/pg"
+)
+
+const (
+	defaultExpiringWithin = "14d"
+	tabPadding            = 2
+)
+
+// handleCommand runs a command (e.a. report expiring) instead of reconciling
+func (pfh PgFgaHandler) handleCommand(args []string) error {
+	if len(args) >= 2 && args[0] == "report" && args[1] == "expiring" {
+		return pfh.reportExpiring(args[2:], os.Stdout)
+	}
+	if len(args) == 2 && args[0] == "report" && args[1] == "managed" {
+		return pfh.reportManaged(os.Stdout)
+	}
+	if len(args) == 2 && args[0] == "report" && args[1] == "extensions" {
+		return pfh.reportExtensions(os.Stdout)
+	}
+	if len(args) == 2 && args[0] == "report" && args[1] == "slots" {
+		return pfh.reportSlots(os.Stdout)
+	}
+	if len(args) == 2 && args[0] == "report" && args[1] == "settings" {
+		return pfh.reportSettings(os.Stdout)
+	}
+	return fmt.Errorf("unknown command %v (supported: report expiring [--within 14d], report managed, "+
+		"report extensions, report slots, report settings)", args)
+}
+
+// reportExpiring lists all users that are expired, or expire within the requested period
+func (pfh PgFgaHandler) reportExpiring(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("report expiring", flag.ContinueOnError)
+	within := flags.String("within", defaultExpiringWithin, "Report users that expire within this period (e.a. 14d)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	period, err := duration.Parse(*within)
+	if err != nil {
+		return err
+	}
+	expiring, err := pfh.pg.ExpiringRoles(period)
+	if err != nil {
+		return err
+	}
+	return writeExpiringReport(out, expiring, time.Now())
+}
+
+func writeExpiringReport(out io.Writer, expiring []pg.ExpiringRole, now time.Time) error {
+	tw := tabwriter.NewWriter(out, 0, 0, tabPadding, ' ', 0)
+	fmt.Fprintln(tw, "USER\tVALID UNTIL\tSTATUS")
+	for _, role := range expiring {
+		status := fmt.Sprintf("expires in %s", role.ValidUntil.Sub(now).Round(time.Hour))
+		if role.Expired(now) {
+			status = "expired"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", role.Name, role.ValidUntil.Format(time.RFC3339), status)
+	}
+	return tw.Flush()
+}
+
+// reportManaged lists all objects that are registered as created (or adopted) by pgfga
+func (pfh PgFgaHandler) reportManaged(out io.Writer) error {
+	managed, err := pfh.pg.ManagedObjects()
+	if err != nil {
+		return err
+	}
+	return writeManagedReport(out, managed)
+}
+
+func writeManagedReport(out io.Writer, managed []pg.ManagedObject) error {
+	tw := tabwriter.NewWriter(out, 0, 0, tabPadding, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tDATABASE\tNAME\tCREATED AT\tADOPTED AT")
+	for _, obj := range managed {
+		createdAt, adoptedAt := obj.CreatedAt.Format(time.RFC3339), "-"
+		if obj.Adopted {
+			createdAt, adoptedAt = "-", obj.CreatedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", obj.Kind, obj.Database, obj.Name, createdAt, adoptedAt)
+	}
+	return tw.Flush()
+}
+
+// reportExtensions lists the version changes of all extensions in all databases, without applying them
+func (pfh PgFgaHandler) reportExtensions(out io.Writer) error {
+	changes, err := pfh.pg.ExtensionPlan()
+	if err != nil {
+		return err
+	}
+	if err = writeExtensionsReport(out, changes); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if change.Action.Refused() {
+			return fmt.Errorf("%s of extension %s in database %s", change.Action, change.Extension, change.Database)
+		}
+	}
+	return nil
+}
+
+func writeExtensionsReport(out io.Writer, changes []pg.ExtensionVersionChange) error {
+	tw := tabwriter.NewWriter(out, 0, 0, tabPadding, ' ', 0)
+	fmt.Fprintln(tw, "DATABASE\tEXTENSION\tFROM\tTO\tACTION")
+	for _, change := range changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", change.Database, change.Extension,
+			valueOrDash(change.From), valueOrDash(change.To), change.Action)
+	}
+	return tw.Flush()
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// reportSlots lists all replication slots (also the slots that are not defined in the config) with the guardrails
+// they exceed. It returns an error when a slot exceeds its guardrails.
+func (pfh PgFgaHandler) reportSlots(out io.Writer) error {
+	statuses, err := pfh.pg.SlotReport()
+	if err != nil {
+		return err
+	}
+	if err = writeSlotsReport(out, statuses, time.Now()); err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if len(status.Violations) > 0 {
+			return fmt.Errorf("replication slot %s exceeds its guardrails", status.Name)
+		}
+	}
+	return nil
+}
+
+func writeSlotsReport(out io.Writer, statuses []pg.SlotStatus, now time.Time) error {
+	tw := tabwriter.NewWriter(out, 0, 0, tabPadding, ' ', 0)
+	fmt.Fprintln(tw, "SLOT\tTYPE\tDATABASE\tMANAGED\tACTIVE\tRETAINED WAL\tINACTIVE FOR\tSTATUS")
+	for _, status := range statuses {
+		inactiveFor := "-"
+		if !status.InactiveSince.IsZero() {
+			inactiveFor = now.Sub(status.InactiveSince).Round(time.Second).String()
+		}
+		state := "ok"
+		if len(status.Violations) > 0 {
+			state = fmt.Sprintf("%s: %s", status.Action, strings.Join(status.Violations, ", "))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%t\t%d\t%s\t%s\n", status.Name, status.Type, valueOrDash(status.Database),
+			status.Managed, status.Active, status.RetainedWAL, inactiveFor, state)
+	}
+	return tw.Flush()
+}
+
+// reportSettings lists all server settings in the config with their running value, and all settings that are
+// pending a restart. It returns an error when a restart is required.
+func (pfh PgFgaHandler) reportSettings(out io.Writer) error {
+	statuses, err := pfh.pg.ServerSettingsReport()
+	if err != nil {
+		return err
+	}
+	if err = writeSettingsReport(out, statuses); err != nil {
+		return err
+	}
+	var pending []string
+	for _, status := range statuses {
+		if status.PendingRestart {
+			pending = append(pending, status.Name)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("PostgreSQL requires a restart for settings %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+func writeSettingsReport(out io.Writer, statuses []pg.ServerSettingStatus) error {
+	tw := tabwriter.NewWriter(out, 0, 0, tabPadding, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tCONFIGURED\tRUNNING\tSTATUS")
+	for _, status := range statuses {
+		state := "ok"
+		if status.PendingRestart {
+			state = "pending restart"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", status.Name, valueOrDash(status.Configured), valueOrDash(status.Running),
+			state)
+	}
+	return tw.Flush()
+}
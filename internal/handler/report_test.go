@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pgvillage-tools/pgfga/pkg/pg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteExpiringReport(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	require.NoError(t, writeExpiringReport(&out, []pg.ExpiringRole{
+		{Name: "expired_user", ValidUntil: now.Add(-time.Hour)},
+		{Name: "expiring_user", ValidUntil: now.Add(48 * time.Hour)},
+	}, now))
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 3)
+	assert.Contains(t, string(lines[0]), "VALID UNTIL")
+	assert.Contains(t, string(lines[1]), "expired_user")
+	assert.Contains(t, string(lines[1]), "expired")
+	assert.Contains(t, string(lines[2]), "expiring_user")
+	assert.Contains(t, string(lines[2]), "expires in 48h0m0s")
+}
+
+func TestWriteManagedReport(t *testing.T) {
+	createdAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	require.NoError(t, writeManagedReport(&out, []pg.ManagedObject{
+		{Kind: pg.ObjectKindRole, Name: "app", CreatedAt: createdAt},
+		{Kind: pg.ObjectKindSchema, Database: "appdb", Name: "app", CreatedAt: createdAt},
+		{Kind: pg.ObjectKindDatabase, Name: "legacy", CreatedAt: createdAt, Adopted: true},
+	}))
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 4)
+	assert.Contains(t, string(lines[0]), "CREATED AT")
+	assert.Contains(t, string(lines[1]), "role")
+	assert.Regexp(t, `appdb\s+app\s+2030-01-01T00:00:00Z\s+-$`, string(lines[2]))
+	assert.Regexp(t, `legacy\s+-\s+2030-01-01T00:00:00Z$`, string(lines[3]))
+}
+
+func TestWriteExtensionsReport(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeExtensionsReport(&out, []pg.ExtensionVersionChange{
+		{Database: "appdb", Extension: "hstore", To: "1.8", Action: pg.ExtensionActionCreate},
+		{Database: "appdb", Extension: "pgcrypto", From: "1.3", To: "1.2", Action: pg.ExtensionActionDowngrade},
+	}))
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 3)
+	assert.Contains(t, string(lines[0]), "FROM")
+	assert.Regexp(t, `appdb\s+hstore\s+-\s+1.8\s+create`, string(lines[1]))
+	assert.Regexp(t, `appdb\s+pgcrypto\s+1.3\s+1.2\s+downgrade refused`, string(lines[2]))
+}
+
+func TestWriteSlotsReport(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	require.NoError(t, writeSlotsReport(&out, []pg.SlotStatus{
+		{Name: "standby1", Type: pg.SlotTypePhysical, Managed: true, Active: true, RetainedWAL: 1024},
+		{
+			Name: "old_cdc", Type: pg.SlotTypeLogical, Database: "appdb", RetainedWAL: 1 << 30,
+			InactiveSince: now.Add(-2 * time.Hour), Violations: []string{"inactive for 2h0m0s (max 1h0m0s)"},
+			Action: pg.GuardrailActionWarn,
+		},
+	}, now))
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 3)
+	assert.Contains(t, string(lines[0]), "RETAINED WAL")
+	assert.Regexp(t, `standby1\s+physical\s+-\s+true\s+true\s+1024\s+-\s+ok`, string(lines[1]))
+	assert.Regexp(t, `old_cdc\s+logical\s+appdb\s+false\s+false\s+1073741824\s+2h0m0s\s+warn: inactive`,
+		string(lines[2]))
+}
+
+func TestWriteSettingsReport(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeSettingsReport(&out, []pg.ServerSettingStatus{
+		{Name: "shared_preload_libraries", Configured: "pg_stat_statements", PendingRestart: true},
+		{Name: "work_mem", Configured: "64MB", Running: "64MB"},
+	}))
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 3)
+	assert.Contains(t, string(lines[0]), "CONFIGURED")
+	assert.Regexp(t, `shared_preload_libraries\s+pg_stat_statements\s+-\s+pending restart`, string(lines[1]))
+	assert.Regexp(t, `work_mem\s+64MB\s+64MB\s+ok`, string(lines[2]))
+}
+
+func TestHandleUnknownCommand(t *testing.T) {
+	assert.Error(t, PgFgaHandler{}.handleCommand([]string{"unknown"}))
+}
@@ -0,0 +1,137 @@
+// Package credential can be used to retrieve secrets (like passwords) from the config, files, executables or
+// environment variables
+package credential
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	executableBits = 0o111
+)
+
+// Credential is a structure to configure a credential.
+// Credentials can be paased as a string, from a file, or from an environment variable, and can be base64 encoded.
+// Credentials are resolved lazily (when GetCred is called), so the secret is not part of the parsed config.
+type Credential struct {
+	Value  string `yaml:"value"`
+	File   string `yaml:"file"`
+	Env    string `yaml:"env"`
+	Base64 bool   `yaml:"base64"`
+}
+
+// UnmarshalYAML allows a credential to be set as a plain string (which is the same as setting value) or as a map
+func (c *Credential) UnmarshalYAML(unmarshal func(any) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*c = Credential{Value: value}
+		return nil
+	}
+	type plain Credential
+	return unmarshal((*plain)(c))
+}
+
+// MarshalYAML marshals the credential without the secret value
+func (c Credential) MarshalYAML() (any, error) {
+	return c.String(), nil
+}
+
+// String returns a description of the credential source without the secret value, so it can safely be logged
+func (c Credential) String() string {
+	switch {
+	case c.Value != "":
+		return "<redacted>"
+	case c.File != "":
+		return fmt.Sprintf("<from file %s>", c.File)
+	case c.Env != "":
+		return fmt.Sprintf("<from env %s>", c.Env)
+	}
+	return "<empty>"
+}
+
+// IsSet returns true if a source (value, file or environment variable) is set for this credential
+func (c Credential) IsSet() bool {
+	return c.Value != "" || c.File != "" || c.Env != ""
+}
+
+func isExecutable(filename string) (isExecutable bool, err error) {
+	fi, err := os.Lstat(filename)
+	if err != nil {
+		return false, err
+	}
+	mode := fi.Mode()
+	return mode&executableBits == executableBits, nil
+}
+
+func fromExecutable(filename string) (value string, err error) {
+	// The intent is to give an option to use a 3rd party tool to retrieve a password.
+	// Or a script to hash / unhash anyway you like
+	// As such running an arbitrary command set as a parameter is sot of the point.
+	// #nosec
+	out, err := exec.Command(filename).Output()
+	if err != nil {
+		return "", fmt.Errorf("credential executable %s failed: %w", filename, err)
+	}
+	return trimNewline(string(out)), nil
+}
+
+func fromFile(filename string) (value string, err error) {
+	isExec, err := isExecutable(filename)
+	if err != nil {
+		return "", err
+	}
+	if isExec {
+		return fromExecutable(filename)
+	}
+	// The intent is to give an option to retrieve a password from a file.
+	// As such opening a file which name is set by a variable is sort of the point.
+	// #nosec
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return trimNewline(string(data[:])), nil
+}
+
+// trimNewline removes the line ending that files and the output of executables usually end with
+func trimNewline(value string) string {
+	return strings.TrimRight(value, "\r\n")
+}
+
+// GetCred is a method to retrieve the Credential, and return it's unencrypted string value (or an error).
+// value takes precedence over file, and file takes precedence over env.
+func (c Credential) GetCred() (value string, err error) {
+	switch {
+	case c.Value != "":
+		value = c.Value
+	case c.File != "":
+		if value, err = fromFile(c.File); err != nil {
+			return "", err
+		}
+		if value == "" {
+			return "", errors.New("credential file is empty")
+		}
+	case c.Env != "":
+		if value = os.Getenv(c.Env); value == "" {
+			return "", fmt.Errorf("credential environment variable %s is empty", c.Env)
+		}
+	default:
+		return "", errors.New("either value, file or env must be set in a credential")
+	}
+	if c.Base64 {
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", err
+		}
+		value = string(data)
+		if value == "" {
+			return "", errors.New("empty credential after base64 decryption")
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,101 @@
+package credential_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pgvillage-tools/pgfga/pkg/credential"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	fileReadOnly = 0o0600
+	fileExec     = 0o0755
+)
+
+func TestCredential(t *testing.T) {
+	const myFirstValue = "myval1"
+	const mySecondValue = "myval2"
+	myBase64EncryptedValue := base64.StdEncoding.EncodeToString([]byte(mySecondValue))
+	tmpDir, err := os.MkdirTemp("", "Credential")
+	if err != nil {
+		panic(fmt.Errorf("unable to create temp dir: %w", err))
+	}
+	defer os.RemoveAll(tmpDir)
+	myCredFile := path.Join(tmpDir, "my-creds-file")
+	require.NoError(t, os.WriteFile(myCredFile, []byte(myFirstValue+"\n"), fileReadOnly))
+	myExecCredFile := path.Join(tmpDir, "my-creds-exec")
+	require.NoError(t, os.WriteFile(myExecCredFile, []byte("#!/bin/sh\necho "+myFirstValue+"\n"), fileExec))
+	myB64CredFile := path.Join(tmpDir, "my-b64-creds-file")
+	require.NoError(t, os.WriteFile(myB64CredFile, []byte(myBase64EncryptedValue), fileReadOnly))
+	t.Setenv("PGFGA_TEST_CRED", myFirstValue)
+	t.Setenv("PGFGA_TEST_B64_CRED", myBase64EncryptedValue)
+	for _, test := range []struct {
+		value    string
+		file     string
+		env      string
+		base64   bool
+		expected string
+	}{
+		{value: myFirstValue, expected: myFirstValue},
+		{file: myCredFile, expected: myFirstValue},
+		{file: myExecCredFile, expected: myFirstValue},
+		{env: "PGFGA_TEST_CRED", expected: myFirstValue},
+		{value: myBase64EncryptedValue, base64: true, expected: mySecondValue},
+		{file: myB64CredFile, base64: true, expected: mySecondValue},
+		{env: "PGFGA_TEST_B64_CRED", base64: true, expected: mySecondValue},
+		{value: myFirstValue, file: myB64CredFile, expected: myFirstValue},
+	} {
+		t.Logf("test values: %v", test)
+		cred := credential.Credential{
+			Value:  test.value,
+			File:   test.file,
+			Env:    test.env,
+			Base64: test.base64,
+		}
+		myCred, err := cred.GetCred()
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, myCred)
+	}
+}
+
+func TestCredentialErrors(t *testing.T) {
+	failingExec := path.Join(t.TempDir(), "failing-exec")
+	require.NoError(t, os.WriteFile(failingExec, []byte("#!/bin/sh\nexit 1\n"), fileExec))
+	for _, cred := range []credential.Credential{
+		{},
+		{File: failingExec},
+		{Env: "PGFGA_TEST_UNSET_CRED"},
+		{Value: "not base64 encoded", Base64: true},
+	} {
+		_, err := cred.GetCred()
+		assert.Error(t, err)
+	}
+}
+
+func TestCredentialYAML(t *testing.T) {
+	var creds map[string]credential.Credential
+	require.NoError(t, yaml.Unmarshal([]byte(`
+plain: secret
+value:
+  value: secret
+env:
+  env: PGFGA_TEST_CRED
+`), &creds))
+	assert.Equal(t, credential.Credential{Value: "secret"}, creds["plain"])
+	assert.Equal(t, credential.Credential{Value: "secret"}, creds["value"])
+	assert.Equal(t, credential.Credential{Env: "PGFGA_TEST_CRED"}, creds["env"])
+	for _, cred := range creds {
+		assert.True(t, cred.IsSet())
+		assert.NotContains(t, cred.String(), "secret")
+		marshalled, err := yaml.Marshal(cred)
+		require.NoError(t, err)
+		assert.NotContains(t, string(marshalled), "secret")
+	}
+	assert.False(t, credential.Credential{}.IsSet())
+}
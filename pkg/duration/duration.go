@@ -0,0 +1,45 @@
+// Package duration holds a duration type that can also be set in days
+package duration
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// Duration is a time.Duration that can also be set in days (e.a. 90d)
+type Duration time.Duration
+
+// Parse parses a duration string like time.ParseDuration does, but also accepts days (e.a. 90d)
+func Parse(str string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(str, "d"); found {
+		value, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s: %w", str, err)
+		}
+		return time.Duration(value * float64(day)), nil
+	}
+	return time.ParseDuration(str)
+}
+
+// Duration returns the value as a time.Duration
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// UnmarshalYAML converts a yaml string to a Duration
+func (d *Duration) UnmarshalYAML(unmarshal func(any) error) error {
+	var str string
+	if err := unmarshal(&str); err != nil {
+		return err
+	}
+	parsed, err := Parse(str)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
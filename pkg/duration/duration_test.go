@@ -0,0 +1,40 @@
+package duration_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pgvillage-tools/pgfga/pkg/duration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected time.Duration
+	}{
+		{input: "90d", expected: 90 * 24 * time.Hour},
+		{input: "1.5d", expected: 36 * time.Hour},
+		{input: "12h", expected: 12 * time.Hour},
+		{input: "30s", expected: 30 * time.Second},
+	} {
+		parsed, err := duration.Parse(test.input)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, parsed)
+	}
+	for _, invalid := range []string{"d", "ninety days", "90"} {
+		_, err := duration.Parse(invalid)
+		assert.Error(t, err)
+	}
+}
+
+func TestDurationYAML(t *testing.T) {
+	var parsed struct {
+		RotateAfter duration.Duration `yaml:"rotate_after"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte("rotate_after: 14d"), &parsed))
+	assert.Equal(t, 14*24*time.Hour, parsed.RotateAfter.Duration())
+	assert.Error(t, yaml.Unmarshal([]byte("rotate_after: forever"), &parsed))
+}
@@ -1,6 +1,11 @@
 // Package ldap takes care of all communication with the ldap server
 package ldap
 
+import "github.com/pgvillage-tools/pgfga/pkg/credential"
+
+// Credential is kept for backwards compatibility, use credential.Credential instead
+type Credential = credential.Credential
+
 // Config is a struct that can hold all ldap config
 type Config struct {
 	Usr        Credential `yaml:"user"`
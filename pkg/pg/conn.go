@@ -6,16 +6,22 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"strconv"
 
 	"github.com/jackc/pgx/v4"
+	"github.com/pgvillage-tools/pgfga/pkg/credential"
 )
 
 // Conn is a smart PostgreSQL connection, which means that it has layers of methods
 type Conn struct {
 	connParams ConnParams
-	conn       *pgx.Conn
-	ctx        context.Context
-	cancel     context.CancelFunc
+	// password is resolved when connecting, so that it is not part of the connParams
+	password credential.Credential
+	// inventory is used to register created objects, and to only drop registered objects (nil means disabled)
+	inventory *inventory
+	conn      *pgx.Conn
+	ctx       context.Context
+	cancel    context.CancelFunc
 }
 
 // NewConn returns a connection with connection parameters set
@@ -25,6 +31,19 @@ func NewConn(connParams ConnParams) (c Conn) {
 	}
 }
 
+// WithPassword returns a copy of this connection that uses the password from a credential.
+// A password set this way takes precedence over a password in the connection parameters.
+func (c Conn) WithPassword(password credential.Credential) Conn {
+	c.password = password
+	return c
+}
+
+// withInventory returns a copy of this connection that registers created objects in the inventory
+func (c Conn) withInventory(inv *inventory) Conn {
+	c.inventory = inv
+	return c
+}
+
 // Conns is a map of Conn items
 type Conns map[string]Conn
 
@@ -37,7 +56,7 @@ func (c Conn) AsConns() Conns {
 func (c Conn) SwitchDB(db string) Conn {
 	dsn := c.connParams.Clone()
 	dsn[ConnParamDBName] = db
-	return NewConn(dsn)
+	return NewConn(dsn).WithPassword(c.password).withInventory(c.inventory)
 }
 
 // DBName retrieves and returns the name of the database that Conn is connected to
@@ -85,8 +104,16 @@ func (c *Conn) Connect() (err error) {
 		}
 		c.conn = nil
 	}
+	connParams := c.ConnParams()
+	if c.password.IsSet() {
+		password, err := c.password.GetCred()
+		if err != nil {
+			return err
+		}
+		connParams[ConnParamPassword] = password
+	}
 	c.ctx, c.cancel = context.WithCancel(context.Background())
-	c.conn, err = pgx.Connect(c.ctx, c.ConnParams().String())
+	c.conn, err = pgx.Connect(c.ctx, connParams.String())
 	if err != nil {
 		c.conn = nil
 		return err
@@ -147,3 +174,32 @@ func (c *Conn) runQueryGetOneField(query string, args ...any) (answer string, er
 	}
 	return answer, nil
 }
+
+func (c *Conn) runQueryGetOneColumn(query string, args ...any) (answers []string, err error) {
+	err = c.Connect()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.conn.Query(c.ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("runQueryGetOneColumn (%s) failed: %v", query, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var answer string
+		if err = rows.Scan(&answer); err != nil {
+			return nil, fmt.Errorf("runQueryGetOneColumn (%s) failed: %v", query, err)
+		}
+		answers = append(answers, answer)
+	}
+	return answers, rows.Err()
+}
+
+// serverVersion returns the version number of the PostgreSQL server (e.a. 160002 for 16.2)
+func (c *Conn) serverVersion() (version int, err error) {
+	answer, err := c.runQueryGetOneField("SHOW server_version_num")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(answer)
+}
@@ -15,9 +15,11 @@ func connectStringValue(objectName string) (escaped string) {
 // ConnParamKey represents the key of a connection string parameter
 type ConnParamKey string
 
-// In a connect string, the dbname key point to the database name
 const (
+	// ConnParamDBName is the key in a connect string that points to the database name
 	ConnParamDBName ConnParamKey = "dbname"
+	// ConnParamPassword is the key in a connect string that points to the password
+	ConnParamPassword ConnParamKey = "password"
 )
 
 // ConnParams can hold all connection parameters as key, value pairs
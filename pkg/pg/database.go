@@ -42,7 +42,29 @@ type Database struct {
 	Owner      string     `yaml:"owner"`
 	Extensions Extensions `yaml:"extensions"`
 	Schemas    Schemas    `yaml:"schemas"`
-	State      State      `yaml:"state"`
+	// Settings are set for all sessions on this database (ALTER DATABASE ... SET)
+	Settings Settings `yaml:"settings"`
+	// RoleSettings are set for sessions of a role on this database (ALTER ROLE ... IN DATABASE ... SET)
+	RoleSettings map[string]Settings `yaml:"role_settings"`
+	State        State               `yaml:"state"`
+	// PreviousNames are renamed to the name of the database when it does not exist, but a previous name does
+	PreviousNames []string `yaml:"previous_names"`
+	// Force terminates sessions when dropping the database fails
+	Force ForceOptions `yaml:"force"`
+	// SoftDelete renames the database instead of dropping it, and drops it after a retention period
+	SoftDelete SoftDelete `yaml:"soft_delete"`
+	// StrictExtensions drops all extensions that are not in Extensions or AllowedExtensions (defaults to
+	// strict.extensions)
+	StrictExtensions *bool `yaml:"strict_extensions"`
+	// AllowedExtensions are never dropped as undeclared extensions (plpgsql is always allowed)
+	AllowedExtensions []string `yaml:"allowed_extensions"`
+	// Publications and Subscriptions are used for logical replication
+	Publications  Publications  `yaml:"publications"`
+	Subscriptions Subscriptions `yaml:"subscriptions"`
+	// Profiles are names of database profiles that are merged (in order) before the definition of this database
+	Profiles []string `yaml:"profiles"`
+	// strictPreload is set by the pg.Handler (from strict.preload_libraries)
+	strictPreload bool
 }
 
 // NewDatabase can be used to create a new Database object
@@ -74,6 +96,8 @@ func (d *Database) reconcilePrimaryCon(conn Conn) (err error) {
 		},
 		d.create,
 		d.reconcileOwner,
+		d.reconcileSettings,
+		d.reconcileRoleSettings,
 		d.reconcileDbCon,
 	} {
 		err := recFunc(conn)
@@ -92,7 +116,10 @@ func (d *Database) reconcileDbCon(primaryConn Conn) (err error) {
 		d.reconcileReadOnlyGrants,
 		d.reconcileReadWriteGrants,
 		d.reconcileExtensions,
+		d.reconcileUndeclaredExtensions,
 		d.reconcileSchemas,
+		d.reconcilePublications,
+		d.reconcileSubscriptions,
 	} {
 		err := recFunc(&dbConn)
 		if err != nil {
@@ -104,24 +131,75 @@ func (d *Database) reconcileDbCon(primaryConn Conn) (err error) {
 
 // Finalize can be used to drop the database
 func (d *Database) drop(conn Conn) (err error) {
-	if d.State == Present {
+	if d.State != Absent {
 		return nil
 	}
+	if d.SoftDelete.Enabled {
+		return d.softDelete(conn)
+	}
 	exists, err := d.exists(conn)
 	if err != nil {
 		return err
 	}
 	if exists {
-		err = conn.runQueryExec(fmt.Sprintf("DROP DATABASE %s", identifier(d.name)))
+		managed := ManagedObject{Kind: ObjectKindDatabase, Name: d.name}
+		if mayDrop, err := conn.mayDrop(managed); err != nil || !mayDrop {
+			return err
+		}
+		dropQuery := fmt.Sprintf("DROP DATABASE %s", identifier(d.name))
+		err = conn.runQueryExec(dropQuery)
+		if err != nil && d.Force.Enabled {
+			log.Warnf("Could not drop database '%s' (%v), terminating sessions", d.name, err)
+			err = d.forceRetry(conn, func() error { return conn.runQueryExec(dropQuery) })
+		}
 		if err != nil {
 			return err
 		}
 		log.Infof("Database '%s' successfully dropped", d.name)
+		if err = conn.unregisterManaged(managed); err != nil {
+			return err
+		}
 	}
 	d.State = Absent
 	return nil
 }
 
+// terminateSessions blocks new connections to the database, and terminates all sessions after the grace period
+func (d Database) terminateSessions(conn Conn) (err error) {
+	err = conn.runQueryExec(fmt.Sprintf("ALTER DATABASE %s ALLOW_CONNECTIONS false", identifier(d.name)))
+	if err != nil {
+		return err
+	}
+	log.Infof("Blocked new connections to database '%s'", d.name)
+	return sessionsOfDatabase.terminate(conn, d.name, d.Force.gracePeriod())
+}
+
+// allowsConnections returns true if new connections to the database are allowed (datallowconn)
+func (d Database) allowsConnections(conn Conn) (allowed bool, err error) {
+	return conn.runQueryExists("SELECT datname FROM pg_database WHERE datname = $1 AND datallowconn", d.name)
+}
+
+// forceRetry terminates all sessions of the database and runs retry again. When that fails, new connections are
+// allowed again if they were allowed before.
+func (d Database) forceRetry(conn Conn, retry func() error) (err error) {
+	allowed, err := d.allowsConnections(conn)
+	if err != nil {
+		return err
+	}
+	if err = d.terminateSessions(conn); err == nil {
+		if err = retry(); err == nil {
+			return nil
+		}
+	}
+	if allowed {
+		restoreErr := conn.runQueryExec(fmt.Sprintf("ALTER DATABASE %s ALLOW_CONNECTIONS true", identifier(d.name)))
+		if restoreErr != nil {
+			log.Errorf("Could not allow connections to database '%s' again: %v", d.name, restoreErr)
+		}
+	}
+	return err
+}
+
 // Create can be used to make sure the database exists
 func (d Database) reconcileOwner(conn Conn) (err error) {
 	// Check if the owner is properly set
@@ -156,6 +234,44 @@ func (d Database) reconcileOwner(conn Conn) (err error) {
 	return nil
 }
 
+// reconcileSettings can be used to set and reset the settings of the database
+func (d Database) reconcileSettings(conn Conn) (err error) {
+	return settingsTarget{database: d.name}.reconcile(conn, d.Settings)
+}
+
+// reconcileRoleSettings can be used to set and reset the settings of roles in the database.
+// Settings of roles that are not in RoleSettings are reset.
+func (d Database) reconcileRoleSettings(conn Conn) (err error) {
+	if d.RoleSettings == nil {
+		return nil
+	}
+	currentRoles, err := conn.runQueryGetOneColumn(
+		`SELECT rolname FROM pg_db_role_setting s
+		INNER JOIN pg_roles rol ON s.setrole = rol.oid
+		INNER JOIN pg_database db ON s.setdatabase = db.oid
+		WHERE datname = $1`,
+		d.name)
+	if err != nil {
+		return err
+	}
+	for _, roleName := range currentRoles {
+		if _, exists := d.RoleSettings[roleName]; !exists {
+			if err = (settingsTarget{role: roleName, database: d.name}).reconcile(conn, Settings{}); err != nil {
+				return err
+			}
+		}
+	}
+	for roleName, settings := range d.RoleSettings {
+		if err = (Role{Name: roleName, State: Present}).create(conn); err != nil {
+			return err
+		}
+		if err = (settingsTarget{role: roleName, database: d.name}).reconcile(conn, settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // exists can be used to check if the database exists
 func (d Database) exists(conn Conn) (exists bool, err error) {
 	return conn.runQueryExists("SELECT datname FROM pg_database WHERE datname = $1", d.name)
@@ -172,14 +288,20 @@ func (d Database) create(conn Conn) (err error) {
 	}
 	if exists {
 		log.Debugf("Database '%s' already exists", d.name)
-		return nil
+		return conn.adoptManaged(ManagedObject{Kind: ObjectKindDatabase, Name: d.name})
+	}
+	if renamed, err := d.renameFromPrevious(conn); err != nil || renamed {
+		return err
+	}
+	if restored, err := d.restoreSoftDeleted(conn); err != nil || restored {
+		return err
 	}
 	err = conn.runQueryExec(fmt.Sprintf("CREATE DATABASE %s", identifier(d.name)))
 	if err != nil {
 		return err
 	}
 	log.Infof("Database '%s' successfully created", d.name)
-	return nil
+	return conn.registerManaged(ManagedObject{Kind: ObjectKindDatabase, Name: d.name})
 }
 
 // reconcileExtensions can be used to make sure the database exists
@@ -187,7 +309,57 @@ func (d Database) reconcileExtensions(dbConn *Conn) (err error) {
 	if d.Extensions == nil {
 		return nil
 	}
-	return d.Extensions.reconcile(dbConn)
+	extensions := Extensions{}
+	for extName, ext := range d.Extensions {
+		ext.strictPreload = d.strictPreload
+		extensions[extName] = ext
+	}
+	return extensions.reconcile(dbConn)
+}
+
+// reconcileUndeclaredExtensions reports all installed extensions that are not declared, and drops them when
+// extensions are strict for this database
+func (d Database) reconcileUndeclaredExtensions(dbConn *Conn) (err error) {
+	undeclared, err := d.Extensions.undeclared(dbConn, d.AllowedExtensions)
+	if err != nil {
+		return err
+	}
+	if !d.strictExtensions() {
+		for _, extName := range undeclared {
+			log.Infof("Extension '%s'.'%s' is installed, but not declared", dbConn.DBName(), extName)
+		}
+		return nil
+	}
+	for _, extName := range undeclared {
+		log.Warnf("Dropping extension '%s'.'%s', because it is not declared", dbConn.DBName(), extName)
+		ext := Extension{name: extName, State: Absent}
+		if err = ext.drop(dbConn); errors.Is(err, errExtensionInUse) {
+			log.Errorf("Not dropping undeclared extension '%s'.'%s': %v", dbConn.DBName(), extName, err)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d Database) strictExtensions() bool {
+	return d.StrictExtensions != nil && *d.StrictExtensions
+}
+
+// reconcilePublications can be used to create, alter and drop publications
+func (d Database) reconcilePublications(dbConn *Conn) (err error) {
+	if d.Publications == nil {
+		return nil
+	}
+	return d.Publications.reconcile(dbConn)
+}
+
+// reconcileSubscriptions can be used to create, alter and drop subscriptions
+func (d Database) reconcileSubscriptions(dbConn *Conn) (err error) {
+	if d.Subscriptions == nil {
+		return nil
+	}
+	return d.Subscriptions.reconcile(dbConn)
 }
 
 // reconcileSchemas can be used to create schemas and set owners of schemas
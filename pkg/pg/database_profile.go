@@ -0,0 +1,97 @@
+package pg
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Merge returns a copy of this database with the definition of another database (e.a. the database itself on top of
+// a profile) merged in. Values that are set in other take precedence, and extensions, schemas and settings are merged
+// per key.
+func (d Database) Merge(other Database) Database {
+	merged := d
+	merged.name = other.name
+	merged.State = other.State
+	merged.Profiles = other.Profiles
+	if other.Owner != "" {
+		merged.Owner = other.Owner
+	}
+	merged.Extensions = mergeMaps(d.Extensions, other.Extensions)
+	merged.Schemas = mergeMaps(d.Schemas, other.Schemas)
+	merged.Publications = mergeMaps(d.Publications, other.Publications)
+	merged.Subscriptions = mergeMaps(d.Subscriptions, other.Subscriptions)
+	merged.Settings = mergeMaps(d.Settings, other.Settings)
+	if d.RoleSettings != nil || other.RoleSettings != nil {
+		merged.RoleSettings = map[string]Settings{}
+		for roleName, settings := range d.RoleSettings {
+			merged.RoleSettings[roleName] = maps.Clone(settings)
+		}
+		for roleName, settings := range other.RoleSettings {
+			merged.RoleSettings[roleName] = mergeMaps(merged.RoleSettings[roleName], settings)
+		}
+	}
+	merged.PreviousNames = appendUnique(slices.Clone(d.PreviousNames), other.PreviousNames...)
+	if other.Force.Enabled {
+		merged.Force = other.Force
+	}
+	if other.SoftDelete.Enabled {
+		merged.SoftDelete = other.SoftDelete
+	}
+	if other.StrictExtensions != nil {
+		strict := *other.StrictExtensions
+		merged.StrictExtensions = &strict
+	}
+	merged.AllowedExtensions = appendUnique(slices.Clone(d.AllowedExtensions), other.AllowedExtensions...)
+	return merged
+}
+
+// mergeMaps returns a new map with all keys of base and other, where values of other take precedence.
+// It returns nil when both are nil, so that unmanaged settings stay unmanaged.
+func mergeMaps[M ~map[K]V, K comparable, V any](base M, other M) M {
+	if base == nil && other == nil {
+		return nil
+	}
+	merged := make(M, len(base)+len(other))
+	maps.Copy(merged, base)
+	maps.Copy(merged, other)
+	return merged
+}
+
+// appendUnique appends all values that are not in list yet
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		if !slices.Contains(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// ApplyProfiles returns the databases with their profiles merged in. The default profile (when set) is applied to
+// every database with state Present first, then the profiles of the database in order, and finally the database
+// definition itself.
+func (d Databases) ApplyProfiles(profiles Databases, defaultProfile string) (Databases, error) {
+	applied := make(Databases, len(d))
+	for dbName, db := range d {
+		if db.State != Present {
+			applied[dbName] = db
+			continue
+		}
+		profileNames := db.Profiles
+		if defaultProfile != "" && !slices.Contains(profileNames, defaultProfile) {
+			profileNames = append([]string{defaultProfile}, profileNames...)
+		}
+		merged := Database{}
+		for _, profileName := range profileNames {
+			profile, exists := profiles[profileName]
+			if !exists {
+				return nil, fmt.Errorf("database %s uses profile %s, which is not defined in database_profiles",
+					dbName, profileName)
+			}
+			merged = merged.Merge(profile)
+		}
+		applied[dbName] = merged.Merge(db)
+	}
+	return applied, nil
+}
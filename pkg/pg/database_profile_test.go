@@ -0,0 +1,62 @@
+package pg
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/DatabaseProfile", func() {
+	strict := true
+	profiles := Databases{
+		"monitoring": Database{
+			Extensions:        Extensions{"pg_stat_statements": Extension{Schema: "monitoring"}},
+			Settings:          Settings{"statement_timeout": "30s", "work_mem": "4MB"},
+			AllowedExtensions: []string{"pg_buffercache"},
+		},
+		"crypto": Database{
+			Extensions:       Extensions{"pgcrypto": Extension{}},
+			Schemas:          Schemas{"vault": Schema{Owner: "vault"}},
+			StrictExtensions: &strict,
+		},
+	}
+	Context("ApplyProfiles", func() {
+		It("should merge the default profile, the profiles in order and the database", func() {
+			applied, err := Databases{
+				"appdb": Database{
+					Owner:      "app",
+					Profiles:   []string{"crypto"},
+					Extensions: Extensions{"pg_stat_statements": Extension{Version: "1.10"}},
+					Settings:   Settings{"statement_timeout": "5s"},
+				},
+			}.ApplyProfiles(profiles, "monitoring")
+			Ω(err).NotTo(HaveOccurred())
+			appDB := applied["appdb"]
+			Ω(appDB.Owner).To(Equal("app"))
+			Ω(appDB.Extensions).To(Equal(Extensions{
+				"pg_stat_statements": Extension{Version: "1.10"},
+				"pgcrypto":           Extension{},
+			}))
+			Ω(appDB.Schemas).To(HaveKey("vault"))
+			Ω(appDB.Settings).To(Equal(Settings{"statement_timeout": "5s", "work_mem": "4MB"}))
+			Ω(appDB.strictExtensions()).To(BeTrue())
+			Ω(appDB.AllowedExtensions).To(Equal([]string{"pg_buffercache"}))
+			Ω(appDB.RoleSettings).To(BeNil())
+		})
+		It("should not change the profiles", func() {
+			_, err := Databases{
+				"appdb": Database{Extensions: Extensions{"hstore": Extension{}}},
+			}.ApplyProfiles(profiles, "monitoring")
+			Ω(err).NotTo(HaveOccurred())
+			Ω(profiles["monitoring"].Extensions).To(HaveLen(1))
+		})
+		It("should not apply profiles to absent databases", func() {
+			applied, err := Databases{"olddb": Database{State: Absent}}.ApplyProfiles(profiles, "monitoring")
+			Ω(err).NotTo(HaveOccurred())
+			Ω(applied["olddb"].Extensions).To(BeNil())
+		})
+		It("should fail on unknown profiles", func() {
+			_, err := Databases{"appdb": Database{Profiles: []string{"unknown"}}}.ApplyProfiles(profiles, "")
+			Ω(err).To(MatchError(ContainSubstring("profile unknown")))
+		})
+	})
+})
@@ -1,25 +1,153 @@
 package pg
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 )
 
+// errExtensionInUse is returned when an extension is not dropped, because other objects depend on it
+var errExtensionInUse = errors.New("other objects depend on it")
+
 // Extensions represent a list of defined extensions to be installed or
 // uninstalled in a database
 type Extensions map[string]Extension
 
-// reconcile can be used to grant or revoke all Databases.
+// reconcile can be used to create, update or drop all Extensions. Extensions are created after the extensions they
+// require, and dropped before the extensions they require.
 func (e Extensions) reconcile(dbConn *Conn) (err error) {
-	for extName, ext := range e {
+	order, err := e.dependencyOrder(dbConn)
+	if err != nil {
+		return err
+	}
+	var absent []string
+	for _, extName := range order {
+		ext := e[extName]
 		ext.name = extName
-		err := ext.reconcile(dbConn)
-		if err != nil {
+		if ext.State == Absent {
+			absent = append(absent, extName)
+			continue
+		}
+		if err = ext.reconcile(dbConn); err != nil {
+			return err
+		}
+	}
+	slices.Reverse(absent)
+	for _, extName := range absent {
+		ext := e[extName]
+		ext.name = extName
+		if err = ext.reconcile(dbConn); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// undeclared returns all installed extensions that are not declared, in the order they can be dropped (extensions
+// before the extensions they require). plpgsql, the allowed extensions and the extensions they or declared extensions
+// require (e.a. cube, when installed with CASCADE for earthdistance) are never returned.
+func (e Extensions) undeclared(dbConn *Conn, allowed []string) (undeclared []string, err error) {
+	installed, err := dbConn.runQueryGetOneColumn("SELECT extname FROM pg_extension ORDER BY extname")
+	if err != nil {
+		return nil, err
+	}
+	requires := map[string][]string{}
+	for _, extName := range installed {
+		if requires[extName], err = (Extension{name: extName}).installedRequires(dbConn); err != nil {
+			return nil, err
+		}
+	}
+	if undeclared, err = sortByDependencies(e.undeclaredOf(installed, allowed, requires), requires); err != nil {
+		return nil, err
+	}
+	slices.Reverse(undeclared)
+	return undeclared, nil
+}
+
+// undeclaredOf returns the installed extensions that are not declared, allowed, or required (directly or indirectly)
+// by a declared or allowed extension
+func (e Extensions) undeclaredOf(installed []string, allowed []string, requires map[string][]string) []string {
+	kept := map[string]bool{}
+	var keep func(extName string)
+	keep = func(extName string) {
+		if kept[extName] {
+			return
+		}
+		kept[extName] = true
+		for _, required := range requires[extName] {
+			keep(required)
+		}
+	}
+	for _, extName := range installed {
+		if ext, declared := e[extName]; (declared && ext.State != Absent) || slices.Contains(allowed, extName) {
+			keep(extName)
+		}
+	}
+	var undeclared []string
+	for _, extName := range installed {
+		if _, declared := e[extName]; !declared && !kept[extName] && extName != "plpgsql" {
+			undeclared = append(undeclared, extName)
+		}
+	}
+	return undeclared
+}
+
+// dependencyOrder returns the names of all extensions, where required extensions come before the extensions that
+// require them
+func (e Extensions) dependencyOrder(dbConn *Conn) (order []string, err error) {
+	requires := map[string][]string{}
+	for extName, ext := range e {
+		ext.name = extName
+		if requires[extName], err = ext.requires(dbConn); err != nil {
+			return nil, err
+		}
+	}
+	return sortByDependencies(slices.Sorted(maps.Keys(e)), requires)
+}
+
+// sortByDependencies sorts names so that every name comes after the names it requires. Requirements that are not in
+// names are ignored.
+func sortByDependencies(names []string, requires map[string][]string) (sorted []string, err error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	for _, name := range names {
+		state[name] = unvisited
+	}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("circular dependency between extensions %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		for _, required := range requires[name] {
+			if _, defined := state[required]; !defined {
+				continue
+			}
+			if err := visit(required, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		sorted = append(sorted, name)
+		return nil
+	}
+	for _, name := range names {
+		if err = visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
 // Extension represents an extension installed in a database
 type Extension struct {
 	// name and db are set by the database
@@ -27,6 +155,15 @@ type Extension struct {
 	Schema  string `yaml:"schema"`
 	State   State  `yaml:"state"`
 	Version string `yaml:"version"`
+	// Cascade also installs all extensions that this extension requires (CREATE EXTENSION ... CASCADE)
+	Cascade bool `yaml:"cascade"`
+	// Force drops this extension, even when other objects depend on it (DROP EXTENSION ... CASCADE)
+	Force bool `yaml:"force"`
+	// PreloadLibrary is the library this extension requires in shared_preload_libraries (known extensions like
+	// pg_stat_statements have a default)
+	PreloadLibrary string `yaml:"preload_library"`
+	// strictPreload is set by the database, and makes a missing preload library an error instead of a warning
+	strictPreload bool
 }
 
 // reconcile can be used to grant or revoke all Roles.
@@ -53,10 +190,27 @@ func (e *Extension) drop(dbConn *Conn) (err error) {
 	if err != nil {
 		return err
 	}
-	if exists {
+	if !exists {
 		log.Debugf("Extension '%s'.'%s' already gone.", dbConn.DBName(), e.name)
+		return nil
 	}
-	err = dbConn.runQueryExec("DROP EXTENSION IF EXISTS " + identifier(e.name))
+	dependents, err := e.dependents(dbConn)
+	if err != nil {
+		return err
+	}
+	dropQry := "DROP EXTENSION IF EXISTS " + identifier(e.name)
+	if len(dependents) > 0 {
+		if !e.Force {
+			return fmt.Errorf("cannot drop extension %s in database %s, because %w: %s",
+				e.name, dbConn.DBName(), errExtensionInUse, strings.Join(dependents, ", "))
+		}
+		for _, dependent := range dependents {
+			log.Warnf("Dropping %s in database '%s', because it depends on extension '%s'",
+				dependent, dbConn.DBName(), e.name)
+		}
+		dropQry += " CASCADE"
+	}
+	err = dbConn.runQueryExec(dropQry)
 	if err != nil {
 		return err
 	}
@@ -70,12 +224,65 @@ func (e Extension) available(conn *Conn) (exists bool, err error) {
 		"SELECT name FROM pg_available_Extensions WHERE name = $1", e.name)
 }
 
-func (e Extension) versionAvailable(conn *Conn) (exists bool, err error) {
+func (e Extension) versionAvailable(conn *Conn, version string) (exists bool, err error) {
 	return conn.runQueryExists(
 		//revive:disable-next-line
 		"SELECT name FROM pg_available_Extension_versions WHERE name = $1 AND version = $2",
 		e.name,
-		e.Version,
+		version,
+	)
+}
+
+// requires returns the extensions that the (requested or default) version of this extension requires
+func (e Extension) requires(conn *Conn) (required []string, err error) {
+	version, err := e.targetVersion(conn)
+	if err != nil {
+		return nil, err
+	}
+	return conn.runQueryGetOneColumn(
+		`SELECT unnest(requires)::text FROM pg_available_extension_versions v
+		INNER JOIN pg_available_extensions a ON v.name = a.name
+		WHERE v.name = $1 AND v.version = COALESCE(NULLIF($2, ''), a.default_version)`,
+		e.name,
+		version,
+	)
+}
+
+// installedRequires returns the installed extensions that the installed version of this extension requires
+func (e Extension) installedRequires(conn *Conn) (required []string, err error) {
+	return conn.runQueryGetOneColumn(
+		`SELECT req.extname FROM pg_extension ext
+		INNER JOIN pg_depend dep ON dep.classid = 'pg_extension'::regclass AND dep.objid = ext.oid
+		INNER JOIN pg_extension req ON dep.refclassid = 'pg_extension'::regclass AND dep.refobjid = req.oid
+		WHERE ext.extname = $1
+		ORDER BY req.extname`,
+		e.name,
+	)
+}
+
+// dependents returns a description of all objects outside of this extension that depend on it, including other
+// extensions and user objects that use objects of this extension (e.a. a column with a type of this extension)
+func (e Extension) dependents(conn *Conn) (dependents []string, err error) {
+	return conn.runQueryGetOneColumn(
+		`WITH ext AS (SELECT oid FROM pg_extension WHERE extname = $1)
+		SELECT DISTINCT pg_describe_object(dep.classid, dep.objid, dep.objsubid)
+		FROM pg_depend member
+		INNER JOIN pg_depend dep ON dep.refclassid = member.classid AND dep.refobjid = member.objid
+		WHERE member.refclassid = 'pg_extension'::regclass
+		AND member.refobjid = (SELECT oid FROM ext)
+		AND member.deptype = 'e'
+		AND dep.deptype IN ('n', 'a')
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_depend own
+			WHERE own.classid = dep.classid AND own.objid = dep.objid AND own.deptype = 'e')
+		UNION
+		SELECT pg_describe_object(classid, objid, objsubid)
+		FROM pg_depend
+		WHERE refclassid = 'pg_extension'::regclass
+		AND refobjid = (SELECT oid FROM ext)
+		AND deptype = 'n'
+		ORDER BY 1`,
+		e.name,
 	)
 }
 
@@ -96,13 +303,17 @@ func (e Extension) create(conn *Conn) (err error) {
 	if !available {
 		return fmt.Errorf("Extension %s is not available", e.name)
 	}
-	if e.Version != "" {
-		versionAvailable, err := e.versionAvailable(conn)
+	version, err := e.targetVersion(conn)
+	if err != nil {
+		return err
+	}
+	if version != "" {
+		versionAvailable, err := e.versionAvailable(conn, version)
 		if err != nil {
 			return err
 		}
 		if !versionAvailable {
-			return fmt.Errorf("version %s is not available for Extension %s", e.Version, e.name)
+			return fmt.Errorf("version %s is not available for Extension %s", version, e.name)
 		}
 	}
 	exists, err := e.exists(conn)
@@ -113,6 +324,9 @@ func (e Extension) create(conn *Conn) (err error) {
 		log.Debugf("Extension '%s'.'%s' already exists.", conn.DBName(), e.name)
 		return nil
 	}
+	if err = e.checkPreload(conn); err != nil {
+		return err
+	}
 	createQry := "CREATE EXTENSION IF NOT EXISTS " + identifier(e.name)
 	if e.Schema != "" {
 		err = Schema{name: e.Schema}.create(conn)
@@ -121,8 +335,11 @@ func (e Extension) create(conn *Conn) (err error) {
 		}
 		createQry += " SCHEMA " + identifier(e.Schema)
 	}
-	if e.Version != "" {
-		createQry += " VERSION " + identifier(e.Version)
+	if version != "" {
+		createQry += " VERSION " + identifier(version)
+	}
+	if e.Cascade {
+		createQry += " CASCADE"
 	}
 	err = conn.runQueryExec(createQry)
 	if err != nil {
@@ -138,27 +355,6 @@ func (e Extension) currentVersion(conn *Conn) (curSchema string, err error) {
 		e.name)
 }
 
-func (e Extension) reconcileVersion(conn *Conn) (err error) {
-	if e.State != Present {
-		return nil
-	}
-	if e.Version != "" {
-		currentVersion, err := e.currentVersion(conn)
-		if err != nil {
-			return err
-		}
-		if currentVersion != e.Version {
-			err = conn.runQueryExec(fmt.Sprintf("ALTER EXTENSION %s UPDATE TO %s", identifier(e.name),
-				quotedSQLValue(e.Version)))
-			if err != nil {
-				return err
-			}
-			log.Infof("Extension '%s'.'%s' successfully updated to version '%s'", conn.DBName(), e.name, e.Version)
-		}
-	}
-	return nil
-}
-
 func (e Extension) currentSchema(conn *Conn) (curSchema string, err error) {
 	qry := `SELECT pg_namespace.nspname 
 				FROM pg_Extension INNER JOIN pg_namespace
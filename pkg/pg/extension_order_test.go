@@ -0,0 +1,48 @@
+package pg
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/Extension/sortByDependencies", func() {
+	It("should sort required extensions before the extensions that require them", func() {
+		sorted, err := sortByDependencies(
+			[]string{"earthdistance", "cube", "postgis_topology", "postgis"},
+			map[string][]string{
+				"earthdistance":    {"cube"},
+				"postgis_topology": {"postgis"},
+			})
+		Ω(err).NotTo(HaveOccurred())
+		Ω(sorted).To(Equal([]string{"cube", "earthdistance", "postgis", "postgis_topology"}))
+	})
+	It("should ignore requirements that are not defined", func() {
+		sorted, err := sortByDependencies([]string{"earthdistance"}, map[string][]string{"earthdistance": {"cube"}})
+		Ω(err).NotTo(HaveOccurred())
+		Ω(sorted).To(Equal([]string{"earthdistance"}))
+	})
+	It("should report circular dependencies", func() {
+		_, err := sortByDependencies([]string{"a", "b"}, map[string][]string{"a": {"b"}, "b": {"a"}})
+		Ω(err).To(MatchError(ContainSubstring("a -> b -> a")))
+	})
+})
+
+var _ = Describe("Pkg/Pg/Extension/undeclaredOf", func() {
+	requires := map[string][]string{
+		"earthdistance": {"cube"},
+		"hstore_plperl": {"hstore", "plperl"},
+	}
+	installed := []string{"cube", "earthdistance", "hstore", "hstore_plperl", "plperl", "plpgsql", "pg_trgm"}
+	It("should not return extensions that declared extensions require", func() {
+		Ω(Extensions{"earthdistance": {}}.undeclaredOf(installed, nil, requires)).To(
+			Equal([]string{"hstore", "hstore_plperl", "plperl", "pg_trgm"}))
+	})
+	It("should not return extensions that allowed extensions require", func() {
+		Ω(Extensions{}.undeclaredOf(installed, []string{"hstore_plperl"}, requires)).To(
+			Equal([]string{"cube", "earthdistance", "pg_trgm"}))
+	})
+	It("should return extensions that are only required by absent extensions", func() {
+		Ω(Extensions{"earthdistance": {State: Absent}}.undeclaredOf(installed, nil, requires)).To(
+			Equal([]string{"cube", "hstore", "hstore_plperl", "plperl", "pg_trgm"}))
+	})
+})
@@ -0,0 +1,258 @@
+package pg
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ExtensionVersionLatest can be set as version to track the default version of an extension
+// (pg_available_extensions.default_version)
+const ExtensionVersionLatest = "latest"
+
+// ExtensionAction is the change that pgfga applies (or refuses to apply) to an extension
+type ExtensionAction string
+
+const (
+	// ExtensionActionNone means that the extension is already as requested
+	ExtensionActionNone ExtensionAction = "none"
+	// ExtensionActionCreate means that the extension will be created
+	ExtensionActionCreate ExtensionAction = "create"
+	// ExtensionActionUpdate means that the extension will be updated to a newer version
+	ExtensionActionUpdate ExtensionAction = "update"
+	// ExtensionActionDrop means that the extension will be dropped
+	ExtensionActionDrop ExtensionAction = "drop"
+	// ExtensionActionUndeclared means that the extension is installed but not declared, and will not be dropped
+	ExtensionActionUndeclared ExtensionAction = "undeclared"
+	// ExtensionActionDowngrade means that a downgrade was requested, which pgfga refuses
+	ExtensionActionDowngrade ExtensionAction = "downgrade refused"
+	// ExtensionActionNoPath means that there is no update path to the requested version
+	ExtensionActionNoPath ExtensionAction = "no update path"
+)
+
+// Refused returns true if pgfga will not apply the change
+func (ea ExtensionAction) Refused() bool {
+	return ea == ExtensionActionDowngrade || ea == ExtensionActionNoPath
+}
+
+// ExtensionVersionChange describes the change of an extension in a database from one version to another
+type ExtensionVersionChange struct {
+	Database  string
+	Extension string
+	// From is the installed version (empty when not installed)
+	From string
+	// To is the requested version (empty when it should not be installed)
+	To     string
+	Action ExtensionAction
+}
+
+func (evc ExtensionVersionChange) err() error {
+	switch evc.Action {
+	case ExtensionActionDowngrade:
+		return fmt.Errorf("refusing to downgrade extension %s in database %s from version %s to %s",
+			evc.Extension, evc.Database, evc.From, evc.To)
+	case ExtensionActionNoPath:
+		return fmt.Errorf("extension %s in database %s cannot be updated from version %s to %s (no update path)",
+			evc.Extension, evc.Database, evc.From, evc.To)
+	}
+	return nil
+}
+
+// versionAction returns the action that is required to change an installed extension from one version to another.
+// Versions of extensions cannot be compared, so a change without an update path is a downgrade when an update path
+// exists the other way around.
+func versionAction(from string, to string, upgradePath bool, downgradePath bool) ExtensionAction {
+	switch {
+	case to == "" || from == to:
+		return ExtensionActionNone
+	case upgradePath:
+		return ExtensionActionUpdate
+	case downgradePath:
+		return ExtensionActionDowngrade
+	default:
+		return ExtensionActionNoPath
+	}
+}
+
+// targetVersion returns the version this extension should have, resolving latest to the default version.
+// It returns an empty string when no version is requested.
+func (e Extension) targetVersion(conn *Conn) (version string, err error) {
+	if e.Version != ExtensionVersionLatest {
+		return e.Version, nil
+	}
+	return e.defaultVersion(conn)
+}
+
+// defaultVersion returns the version that is installed when no version is specified (or an empty string when the
+// extension is not available)
+func (e Extension) defaultVersion(conn *Conn) (version string, err error) {
+	versions, err := conn.runQueryGetOneColumn(
+		"SELECT default_version FROM pg_available_extensions WHERE name = $1", e.name)
+	if err != nil || len(versions) == 0 {
+		return "", err
+	}
+	return versions[0], nil
+}
+
+// hasUpdatePath returns true if the extension can be updated from one version to another
+func (e Extension) hasUpdatePath(conn *Conn, from string, to string) (exists bool, err error) {
+	return conn.runQueryExists(
+		"SELECT path FROM pg_extension_update_paths($1) WHERE source = $2 AND target = $3 AND path IS NOT NULL",
+		e.name, from, to)
+}
+
+// updateTargets returns all versions the extension can be updated to from the specified version
+func (e Extension) updateTargets(conn *Conn, from string) (targets []string, err error) {
+	return conn.runQueryGetOneColumn(
+		"SELECT target FROM pg_extension_update_paths($1) WHERE source = $2 AND path IS NOT NULL ORDER BY target",
+		e.name, from)
+}
+
+// versionChange returns the change that is required for this extension in the database of the connection.
+// When the database does not exist yet (dbExists is false), the extension is considered not installed.
+func (e Extension) versionChange(conn *Conn, dbName string, dbExists bool) (change ExtensionVersionChange, err error) {
+	change = ExtensionVersionChange{Database: dbName, Extension: e.name, Action: ExtensionActionNone}
+	installed := false
+	if dbExists {
+		if installed, err = e.exists(conn); err != nil {
+			return change, err
+		}
+	}
+	if installed {
+		if change.From, err = e.currentVersion(conn); err != nil {
+			return change, err
+		}
+	}
+	if e.State != Present {
+		if installed {
+			change.Action = ExtensionActionDrop
+		}
+		return change, nil
+	}
+	if change.To, err = e.targetVersion(conn); err != nil {
+		return change, err
+	}
+	if !installed {
+		if change.To == "" {
+			change.To, err = e.defaultVersion(conn)
+		}
+		change.Action = ExtensionActionCreate
+		return change, err
+	}
+	if change.To == "" || change.From == change.To {
+		change.To = change.From
+		return change, nil
+	}
+	upgradePath, err := e.hasUpdatePath(conn, change.From, change.To)
+	if err != nil {
+		return change, err
+	}
+	downgradePath := false
+	if !upgradePath {
+		if downgradePath, err = e.hasUpdatePath(conn, change.To, change.From); err != nil {
+			return change, err
+		}
+	}
+	change.Action = versionAction(change.From, change.To, upgradePath, downgradePath)
+	return change, nil
+}
+
+// reconcileVersion updates the extension to the requested version. Downgrades and versions without an update path
+// are refused with an error.
+func (e Extension) reconcileVersion(conn *Conn) (err error) {
+	if e.State != Present || e.Version == "" {
+		return nil
+	}
+	change, err := e.versionChange(conn, conn.DBName(), true)
+	if err != nil {
+		return err
+	}
+	if change.Action == ExtensionActionNoPath {
+		targets, err := e.updateTargets(conn, change.From)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			targets = []string{"none"}
+		}
+		return fmt.Errorf("%w; available updates: %s", change.err(), strings.Join(targets, ", "))
+	}
+	if change.Action != ExtensionActionUpdate {
+		return change.err()
+	}
+	err = conn.runQueryExec(fmt.Sprintf("ALTER EXTENSION %s UPDATE TO %s", identifier(e.name),
+		quotedSQLValue(change.To)))
+	if err != nil {
+		return err
+	}
+	log.Infof("Extension '%s'.'%s' successfully updated from version '%s' to '%s'",
+		conn.DBName(), e.name, change.From, change.To)
+	return nil
+}
+
+// extensionPlan returns the changes to the extensions of this database (in the order they are applied)
+func (d Database) extensionPlan(primaryConn Conn) (changes []ExtensionVersionChange, err error) {
+	if d.State != Present {
+		return nil, nil
+	}
+	dbExists, err := d.exists(primaryConn)
+	if err != nil {
+		return nil, err
+	}
+	conn := primaryConn
+	if dbExists {
+		conn = primaryConn.SwitchDB(d.name)
+		defer conn.Close()
+	}
+	order, err := d.Extensions.dependencyOrder(&conn)
+	if err != nil {
+		return nil, err
+	}
+	for _, extName := range order {
+		ext := d.Extensions[extName]
+		ext.name = extName
+		change, err := ext.versionChange(&conn, d.name, dbExists)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	if !dbExists {
+		return changes, nil
+	}
+	undeclared, err := d.Extensions.undeclared(&conn, d.AllowedExtensions)
+	if err != nil {
+		return nil, err
+	}
+	for _, extName := range undeclared {
+		change := ExtensionVersionChange{Database: d.name, Extension: extName, Action: ExtensionActionUndeclared}
+		if change.From, err = (Extension{name: extName}).currentVersion(&conn); err != nil {
+			return nil, err
+		}
+		if d.strictExtensions() {
+			change.Action = ExtensionActionDrop
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// ExtensionPlan returns for every database the version changes of the extensions, without applying them
+func (h *Handler) ExtensionPlan() (changes []ExtensionVersionChange, err error) {
+	conn := h.getPrimaryConnection()
+	if err = conn.Connect(); err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	for _, dbName := range slices.Sorted(maps.Keys(h.Databases)) {
+		db := h.Databases[dbName]
+		db.name = dbName
+		dbChanges, err := db.extensionPlan(conn)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, dbChanges...)
+	}
+	return changes, nil
+}
@@ -0,0 +1,36 @@
+package pg
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/ExtensionVersion", func() {
+	Context("versionAction", func() {
+		It("should detect updates, downgrades and missing update paths", func() {
+			Ω(versionAction("1.5", "", false, false)).To(Equal(ExtensionActionNone))
+			Ω(versionAction("1.5", "1.5", false, false)).To(Equal(ExtensionActionNone))
+			Ω(versionAction("1.5", "1.6", true, false)).To(Equal(ExtensionActionUpdate))
+			Ω(versionAction("1.6", "1.5", false, true)).To(Equal(ExtensionActionDowngrade))
+			Ω(versionAction("1.5", "9.9", false, false)).To(Equal(ExtensionActionNoPath))
+		})
+	})
+	Context("ExtensionVersionChange", func() {
+		It("should only return an error for refused changes", func() {
+			change := ExtensionVersionChange{Database: "db", Extension: "ext", From: "1.6", To: "1.5"}
+			for _, action := range []ExtensionAction{
+				ExtensionActionNone, ExtensionActionCreate, ExtensionActionUpdate, ExtensionActionDrop,
+			} {
+				change.Action = action
+				Ω(action.Refused()).To(BeFalse())
+				Ω(change.err()).NotTo(HaveOccurred())
+			}
+			change.Action = ExtensionActionDowngrade
+			Ω(change.Action.Refused()).To(BeTrue())
+			Ω(change.err()).To(MatchError(ContainSubstring("refusing to downgrade extension ext in database db")))
+			change.Action = ExtensionActionNoPath
+			Ω(change.Action.Refused()).To(BeTrue())
+			Ω(change.err()).To(MatchError(ContainSubstring("no update path")))
+		})
+	})
+})
@@ -2,6 +2,8 @@ package pg
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 )
 
 // Grants is a list of grants
@@ -29,11 +31,69 @@ func (g Grants) finalize(conn Conn) (err error) {
 	return nil
 }
 
+// declared returns the granted roles per grantee, for all grants that should be present
+func (g Grants) declared() (declared map[string]map[string]bool) {
+	declared = map[string]map[string]bool{}
+	for _, grant := range g {
+		if grant.State == Absent {
+			continue
+		}
+		if _, exists := declared[grant.Grantee.Name]; !exists {
+			declared[grant.Grantee.Name] = map[string]bool{}
+		}
+		declared[grant.Grantee.Name][grant.Granted.Name] = true
+	}
+	return declared
+}
+
+// revokeUndeclared can be used to revoke all memberships of roles with authoritative memberships that are not declared
+// as a grant (including the grants from ldap group expansion).
+func (g Grants) revokeUndeclared(conn Conn, roles Roles) (err error) {
+	declared := g.declared()
+	for _, roleName := range slices.Sorted(maps.Keys(roles)) {
+		role := roles[roleName]
+		if !role.AuthoritativeMemberships || role.State != Present {
+			continue
+		}
+		granteds, err := conn.runQueryGetOneColumn(`SELECT DISTINCT granted.rolname
+			FROM pg_auth_members auth
+			INNER JOIN pg_roles granted ON auth.roleid = granted.oid
+			INNER JOIN pg_roles grantee ON auth.member = grantee.oid
+			WHERE grantee.rolname = $1`, roleName)
+		if err != nil {
+			return err
+		}
+		for _, granted := range granteds {
+			if declared[roleName][granted] {
+				continue
+			}
+			log.Infof("Role '%s' is granted to user '%s', but not declared", granted, roleName)
+			grant := Grant{Grantee: Role{Name: roleName}, Granted: Role{Name: granted}, State: Absent}
+			if err = grant.revoke(conn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Grant is a list of roles granted to a grantee
 type Grant struct {
 	Grantee Role
 	Granted Role
 	State   State
+	// Admin, Inherit and Set are the (optional) grant options. Options that are nil are not managed.
+	Admin   *bool
+	Inherit *bool
+	Set     *bool
+}
+
+func (g Grant) options() grantOptions {
+	return grantOptions{
+		grantOptionAdmin:   g.Admin,
+		grantOptionInherit: g.Inherit,
+		grantOptionSet:     g.Set,
+	}
 }
 
 func (g Grant) String() string {
@@ -61,7 +121,7 @@ func (g Grant) grant(conn Conn) (err error) {
 	}
 	if exists {
 		log.Debugf("Role '%s' already granted to user '%s'", g.Granted.Name, g.Grantee.Name)
-		return nil
+		return g.reconcileOptions(conn)
 	}
 	for _, role := range []Role{g.Granted, g.Grantee} {
 		if role.State == Absent {
@@ -72,7 +132,12 @@ func (g Grant) grant(conn Conn) (err error) {
 		}
 	}
 	g.Granted.create(conn)
-	err = conn.runQueryExec(fmt.Sprintf("GRANT %s TO %s", identifier(g.Granted.Name), identifier(g.Grantee.Name)))
+	serverVersion, err := conn.serverVersion()
+	if err != nil {
+		return err
+	}
+	err = conn.runQueryExec(fmt.Sprintf("GRANT %s TO %s%s", identifier(g.Granted.Name), identifier(g.Grantee.Name),
+		g.options().supported(serverVersion).withSQL(serverVersion)))
 	if err != nil {
 		return err
 	}
@@ -80,9 +145,72 @@ func (g Grant) grant(conn Conn) (err error) {
 	return nil
 }
 
+// currentOptions returns the options of an existing grant. Before PostgreSQL 16 only ADMIN is returned.
+func (g Grant) currentOptions(conn Conn, serverVersion int) (current map[grantOption]bool, err error) {
+	columns := "bool_or(admin_option), false, false"
+	if serverVersion >= pg16VersionNum {
+		columns = "bool_or(admin_option), bool_or(inherit_option), bool_or(set_option)"
+	}
+	if err = conn.Connect(); err != nil {
+		return nil, err
+	}
+	var admin, inherit, set bool
+	err = conn.conn.QueryRow(conn.ctx, fmt.Sprintf(`SELECT %s
+		FROM pg_auth_members auth
+		INNER JOIN pg_roles granted ON auth.roleid = granted.oid
+		INNER JOIN pg_roles grantee ON auth.member = grantee.oid
+		WHERE granted.rolname = $1 AND grantee.rolname = $2`, columns),
+		g.Granted.Name, g.Grantee.Name).Scan(&admin, &inherit, &set)
+	if err != nil {
+		return nil, err
+	}
+	return map[grantOption]bool{
+		grantOptionAdmin:   admin,
+		grantOptionInherit: inherit,
+		grantOptionSet:     set,
+	}, nil
+}
+
+// reconcileOptions can be used to set or revoke the options of an existing grant
+func (g Grant) reconcileOptions(conn Conn) (err error) {
+	if len(g.options().supported(pg16VersionNum)) == 0 {
+		return nil
+	}
+	serverVersion, err := conn.serverVersion()
+	if err != nil {
+		return err
+	}
+	options := g.options().supported(serverVersion)
+	current, err := g.currentOptions(conn, serverVersion)
+	if err != nil {
+		return err
+	}
+	for _, option := range []grantOption{grantOptionAdmin, grantOptionInherit, grantOptionSet} {
+		value, managed := options[option]
+		if !managed || current[option] == *value {
+			continue
+		}
+		if *value {
+			err = conn.runQueryExec(fmt.Sprintf("GRANT %s TO %s%s", identifier(g.Granted.Name),
+				identifier(g.Grantee.Name), grantOptions{option: value}.withSQL(serverVersion)))
+		} else {
+			err = g.revokePerGrantor(conn, serverVersion, fmt.Sprintf("REVOKE %s OPTION FOR %s FROM %s", option,
+				identifier(g.Granted.Name), identifier(g.Grantee.Name)))
+		}
+		if err != nil {
+			return err
+		}
+		log.Infof("Option %s successfully set to %v on grant of role '%s' to user '%s'", option, *value,
+			g.Granted.Name, g.Grantee.Name)
+	}
+	return nil
+}
+
 // RevokeRole can be used to revoke a Role from another Role.
+// Only grants with state Absent are revoked. Like allowed roles and databases, a grant with state Allowed may exist,
+// and is left as it is.
 func (g Grant) revoke(conn Conn) (err error) {
-	if g.State == Present {
+	if g.State != Absent {
 		return nil
 	}
 	exists, err := g.exists(conn)
@@ -90,16 +218,54 @@ func (g Grant) revoke(conn Conn) (err error) {
 		return err
 	}
 	if exists {
-		err = conn.runQueryExec(
-			fmt.Sprintf(
-				"REVOKE %s FROM %s",
-				identifier(g.Granted.Name),
-				identifier(g.Grantee.Name)),
-		)
+		serverVersion, err := conn.serverVersion()
 		if err != nil {
 			return err
 		}
-		log.Infof("Role '%s' successfully revoked from user '%s'", g.Grantee.Name, g.Granted.Name)
+		err = g.revokePerGrantor(conn, serverVersion,
+			fmt.Sprintf("REVOKE %s FROM %s", identifier(g.Granted.Name), identifier(g.Grantee.Name)))
+		if err != nil {
+			return err
+		}
+		log.Infof("Role '%s' successfully revoked from user '%s'", g.Granted.Name, g.Grantee.Name)
+	}
+	return nil
+}
+
+// grantors returns all roles that granted this membership
+func (g Grant) grantors(conn Conn) (grantors []string, err error) {
+	return conn.runQueryGetOneColumn(`SELECT DISTINCT grantor.rolname
+		FROM pg_auth_members auth
+		INNER JOIN pg_roles granted ON auth.roleid = granted.oid
+		INNER JOIN pg_roles grantee ON auth.member = grantee.oid
+		INNER JOIN pg_roles grantor ON auth.grantor = grantor.oid
+		WHERE granted.rolname = $1 AND grantee.rolname = $2
+		ORDER BY 1`,
+		g.Granted.Name, g.Grantee.Name)
+}
+
+// grantedBySQL returns the GRANTED BY part of a REVOKE statement. Before PostgreSQL 16 a membership is not
+// registered per grantor, so the revoke applies to the membership as a whole.
+func grantedBySQL(grantor string, serverVersion int) string {
+	if grantor == "" || serverVersion < pg16VersionNum {
+		return ""
+	}
+	return " GRANTED BY " + identifier(grantor)
+}
+
+// revokePerGrantor runs a REVOKE statement for every grantor of this membership. Since PostgreSQL 16 every grantor
+// has its own membership, and a REVOKE without GRANTED BY only revokes the membership granted by the current user.
+func (g Grant) revokePerGrantor(conn Conn, serverVersion int, revokeQuery string) (err error) {
+	grantors := []string{""}
+	if serverVersion >= pg16VersionNum {
+		if grantors, err = g.grantors(conn); err != nil {
+			return err
+		}
+	}
+	for _, grantor := range grantors {
+		if err = conn.runQueryExec(revokeQuery + grantedBySQL(grantor, serverVersion)); err != nil {
+			return err
+		}
 	}
 	return nil
 }
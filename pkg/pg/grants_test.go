@@ -77,6 +77,17 @@ var _ = Describe("Pkg/Pg/Grants", func() {
 		})
 	})
 	Describe("Grant", func() {
+		Context("revoke", func() {
+			unreachable := NewConn(ConnParams{"host": "/nonexistent"})
+			It("should only revoke grants with state Absent", func() {
+				for _, state := range []State{Present, Allowed} {
+					Ω(Grant{Grantee: Role{Name: "grantee1"}, Granted: Role{Name: "granted1"}, State: state}.revoke(
+						unreachable)).To(Succeed())
+				}
+				Ω(Grant{Grantee: Role{Name: "grantee1"}, Granted: Role{Name: "granted1"}, State: Absent}.revoke(
+					unreachable)).NotTo(Succeed())
+			})
+		})
 		Context("String", func() {
 			It("should be parsable to a string", func() {
 				for _, grantee := range []string{"grantee1", "grantee2"} {
@@ -92,4 +103,24 @@ var _ = Describe("Pkg/Pg/Grants", func() {
 			})
 		})
 	})
+	Describe("grantedBySQL", func() {
+		It("should only add GRANTED BY since PostgreSQL 16", func() {
+			Ω(grantedBySQL("admin", pg16VersionNum)).To(Equal(` GRANTED BY "admin"`))
+			Ω(grantedBySQL("admin", pg15VersionNum)).To(BeEmpty())
+			Ω(grantedBySQL("", pg16VersionNum)).To(BeEmpty())
+		})
+	})
+	Describe("declared", func() {
+		It("should only return memberships that should be present", func() {
+			grants := Grants{
+				{Grantee: Role{Name: "user1"}, Granted: Role{Name: "group1"}},
+				{Grantee: Role{Name: "user1"}, Granted: Role{Name: "group2"}, State: Absent},
+				{Grantee: Role{Name: "user2"}, Granted: Role{Name: "group1"}, State: Present},
+			}
+			Ω(grants.declared()).To(Equal(map[string]map[string]bool{
+				"user1": {"group1": true},
+				"user2": {"group1": true},
+			}))
+		})
+	})
 })
@@ -1,33 +1,50 @@
 package pg
 
+import "github.com/pgvillage-tools/pgfga/pkg/credential"
+
 // Handler holds all data for the Handle Method.
 type Handler struct {
 	defaultDB     string
 	connections   Conns
 	StrictOptions StrictOptions
+	Tablespaces   Tablespaces
 	Databases     Databases
 	Roles         Roles
 	Grants        Grants
-	Slots         replicationSlots
+	Slots         ReplicationSlots
+	// SlotGuardrails are the thresholds for all replication slots (which slots can override)
+	SlotGuardrails SlotGuardrails
+	// ServerSettings are set for the whole cluster (ALTER SYSTEM ... SET)
+	ServerSettings Settings
 }
 
 // NewPgHandler can be used to handle all PostgreSQL actions tha PgFga needs to undertake
-func NewPgHandler(connParams ConnParams, options StrictOptions, databases Databases, slots []string) (ph *Handler) {
-	connection := NewConn(connParams.Clone())
+func NewPgHandler(
+	connParams ConnParams,
+	password credential.Credential,
+	options StrictOptions,
+	tablespaces Tablespaces,
+	databases Databases,
+	slots ReplicationSlots,
+) (ph *Handler) {
+	connection := NewConn(connParams.Clone()).WithPassword(password)
+	if options.ManagedOnly {
+		inv := newInventory(connection)
+		inv.adoptExisting = options.AdoptExisting
+		connection = connection.withInventory(inv)
+	}
 	ph = &Handler{
 		defaultDB:     connection.DBName(),
 		connections:   connection.AsConns(),
 		StrictOptions: options,
+		Tablespaces:   tablespaces,
 		Databases:     databases,
 		Roles:         Roles{"opex": NewRole("opex")},
 		Grants:        Grants{},
-		Slots:         replicationSlots{},
+		Slots:         slots,
 	}
-	for _, slotName := range slots {
-		ph.Slots[slotName] = replicationSlot{
-			name:  slotName,
-			State: Present,
-		}
+	if ph.Slots == nil {
+		ph.Slots = ReplicationSlots{}
 	}
 	ph.setDefaults()
 	return ph
@@ -43,11 +60,22 @@ func (h *Handler) getPrimaryConnection() (dbConn Conn) {
 }
 
 func (h *Handler) setDefaults() {
+	for name, ts := range h.Tablespaces {
+		ts.name = name
+		h.Tablespaces[name] = ts
+	}
 	for name, db := range h.Databases {
 		db.name = name
+		if db.StrictExtensions == nil {
+			strict := h.StrictOptions.Extensions
+			db.StrictExtensions = &strict
+		}
+		db.strictPreload = h.StrictOptions.PreloadLibraries
+		h.Databases[name] = db
 	}
 	for name, rs := range h.Slots {
 		rs.name = name
+		h.Slots[name] = rs
 	}
 }
 
@@ -68,14 +96,37 @@ func (h *Handler) Grant(grantee string, granted string) {
 	h.Grants = append(h.Grants, Grant{Grantee: granteeRole, Granted: grantedRole})
 }
 
+// GrantMembership can be used to update the list of grants for granting a role with grant options to the grantee
+func (h *Handler) GrantMembership(grantee string, membership Membership) {
+	grantedRole := h.GetRole(membership.Role)
+	granteeRole := h.GetRole(grantee)
+	h.Grants = append(h.Grants, Grant{
+		Grantee: granteeRole,
+		Granted: grantedRole,
+		Admin:   membership.Admin,
+		Inherit: membership.Inherit,
+		Set:     membership.Set,
+	})
+}
+
+// revokeUndeclaredGrants can be used to revoke memberships that are not declared from roles with authoritative
+// memberships
+func (h *Handler) revokeUndeclaredGrants(primaryConn Conn) (err error) {
+	return h.Grants.revokeUndeclared(primaryConn, h.Roles)
+}
+
 // Reconcile can be used to reconcile all objects as defined in this handler object
 func (h *Handler) Reconcile() (err error) {
 	primaryConnection := h.getPrimaryConnection()
 	for _, recFunc := range []func(Conn) error{
+		h.reconcileServerSettings,
 		h.Roles.reconcile,
 		h.Grants.reconcile,
+		h.revokeUndeclaredGrants,
+		h.Tablespaces.reconcile,
 		h.Databases.reconcile,
 		h.Slots.reconcile,
+		h.checkSlotGuardrails,
 	} {
 		err := recFunc(primaryConnection)
 		if err != nil {
@@ -90,6 +141,7 @@ func (h *Handler) Finalize() (err error) {
 	primaryConnection := h.getPrimaryConnection()
 	for _, recFunc := range []func(Conn) error{
 		h.Databases.finalize,
+		h.Tablespaces.finalize,
 		h.Grants.finalize,
 		h.Roles.finalize,
 		h.Slots.finalize,
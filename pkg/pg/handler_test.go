@@ -0,0 +1,38 @@
+package pg
+
+import (
+	"github.com/pgvillage-tools/pgfga/pkg/credential"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/Handler", func() {
+	Context("NewPgHandler", func() {
+		It("should default strict_extensions of databases to strict.extensions", func() {
+			notStrict := false
+			h := NewPgHandler(
+				ConnParams{"dbname": "postgres"},
+				credential.Credential{},
+				StrictOptions{Extensions: true},
+				Tablespaces{},
+				Databases{"strict": Database{}, "lenient": Database{StrictExtensions: &notStrict}},
+				nil,
+			)
+			Ω(h.Databases["strict"].name).To(Equal("strict"))
+			Ω(h.Databases["strict"].strictExtensions()).To(BeTrue())
+			Ω(h.Databases["lenient"].strictExtensions()).To(BeFalse())
+		})
+		It("should set the names of tablespaces", func() {
+			h := NewPgHandler(
+				ConnParams{"dbname": "postgres"},
+				credential.Credential{},
+				StrictOptions{},
+				Tablespaces{"fast": Tablespace{Location: "/data/fast"}},
+				Databases{},
+				nil,
+			)
+			Ω(h.Tablespaces["fast"].name).To(Equal("fast"))
+		})
+	})
+})
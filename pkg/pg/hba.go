@@ -0,0 +1,246 @@
+package pg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// HbaRulesMarker is the line in a pg_hba.conf template that is replaced by the generated rules
+	HbaRulesMarker = "# pgfga rules"
+	hbaHeader      = "# This file is generated by pgfga. Changes will be overwritten."
+	hbaFileMode    = 0o600
+)
+
+// HbaRule is a line in pg_hba.conf
+type HbaRule struct {
+	// Type is the connection type (local, host, hostssl, ...)
+	Type      string
+	Databases []string
+	// Users are role names, or group names prefixed with + (which match all members of the group)
+	Users []string
+	// Address is not used for local rules
+	Address string
+	Method  string
+	// Options are the options of the method (e.a. map=pgfga)
+	Options string
+}
+
+func (hr HbaRule) String() string {
+	fields := []string{hr.Type, strings.Join(hr.Databases, ","), strings.Join(hr.Users, ",")}
+	if hr.Type != "local" {
+		fields = append(fields, hr.Address)
+	}
+	fields = append(fields, hr.Method)
+	if hr.Options != "" {
+		fields = append(fields, hr.Options)
+	}
+	return strings.Join(fields, "\t")
+}
+
+// IdentMapping is a line in pg_ident.conf, which maps a system user (e.a. the CN of a client certificate) to a role
+type IdentMapping struct {
+	Map        string
+	SystemUser string
+	PgUser     string
+}
+
+func (im IdentMapping) String() string {
+	return strings.Join([]string{im.Map, im.SystemUser, im.PgUser}, "\t")
+}
+
+// RenderHba returns the content of pg_hba.conf. The rules replace the marker line in the template, or are added after
+// the template when it has no marker line.
+func RenderHba(template string, rules []HbaRule) string {
+	var generated []string
+	for _, rule := range rules {
+		generated = append(generated, rule.String())
+	}
+	lines := []string{hbaHeader}
+	replaced := false
+	for line := range strings.Lines(template) {
+		line = strings.TrimRight(line, "\n")
+		if strings.TrimSpace(line) == HbaRulesMarker {
+			lines = append(lines, generated...)
+			replaced = true
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if !replaced {
+		lines = append(lines, generated...)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// RenderIdent returns the content of pg_ident.conf
+func RenderIdent(mappings []IdentMapping) string {
+	lines := []string{hbaHeader}
+	for _, mapping := range mappings {
+		lines = append(lines, mapping.String())
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// configFile is a file that is written atomically, and can be restored when the new content is invalid
+type configFile struct {
+	path     string
+	setting  string
+	content  string
+	previous []byte
+	existed  bool
+	changed  bool
+}
+
+// write writes the content to a temporary file and renames it, but only when the content differs
+func (cf *configFile) write() (err error) {
+	cf.previous, err = os.ReadFile(cf.path)
+	switch {
+	case err == nil:
+		cf.existed = true
+	case errors.Is(err, os.ErrNotExist):
+		cf.existed = false
+	default:
+		return err
+	}
+	if cf.existed && bytes.Equal(cf.previous, []byte(cf.content)) {
+		return nil
+	}
+	if err = writeAtomically(cf.path, []byte(cf.content)); err != nil {
+		return err
+	}
+	cf.changed = true
+	log.Infof("Written '%s'", cf.path)
+	return nil
+}
+
+// checkServerPath returns an error when the server reads the file from another path (as set in hba_file or ident_file)
+func (cf configFile) checkServerPath(conn Conn) error {
+	serverPath, err := conn.runQueryGetOneField("SHOW " + cf.setting)
+	if err != nil {
+		return err
+	}
+	path, err := filepath.Abs(cf.path)
+	if err != nil {
+		return err
+	}
+	if path != filepath.Clean(serverPath) {
+		return fmt.Errorf("'%s' is configured, but the server uses '%s' (%s)", cf.path, serverPath, cf.setting)
+	}
+	return nil
+}
+
+// reloadPending returns true when the file was changed after the server last loaded its configuration
+func (cf configFile) reloadPending(conn Conn) (bool, error) {
+	info, err := os.Stat(cf.path)
+	if err != nil {
+		return false, err
+	}
+	return conn.runQueryExists("SELECT 'pending' WHERE pg_conf_load_time() < $1", info.ModTime())
+}
+
+// restore puts back the previous content of the file
+func (cf configFile) restore() error {
+	if !cf.changed {
+		return nil
+	}
+	if !cf.existed {
+		return os.Remove(cf.path)
+	}
+	return writeAtomically(cf.path, cf.previous)
+}
+
+func writeAtomically(path string, content []byte) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(hbaFileMode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// hbaErrors returns the errors PostgreSQL reports for the current pg_hba.conf (and pg_ident.conf on PostgreSQL 15 and
+// newer)
+func hbaErrors(conn Conn) (hbaErrors []string, err error) {
+	hbaErrors, err = conn.runQueryGetOneColumn(
+		`SELECT format('pg_hba.conf line %s: %s', line_number, error)
+		FROM pg_hba_file_rules WHERE error IS NOT NULL ORDER BY line_number`)
+	if err != nil {
+		return nil, err
+	}
+	serverVersion, err := conn.serverVersion()
+	if err != nil || serverVersion < pg15VersionNum {
+		return hbaErrors, err
+	}
+	identErrors, err := conn.runQueryGetOneColumn(
+		`SELECT format('pg_ident.conf line %s: %s', line_number, error)
+		FROM pg_ident_file_mappings WHERE error IS NOT NULL ORDER BY line_number`)
+	return append(hbaErrors, identErrors...), err
+}
+
+// ApplyHba writes pg_hba.conf (and pg_ident.conf when identPath is set), validates them with pg_hba_file_rules and
+// reloads the configuration when they changed, or when the server did not load them yet. It fails before writing when
+// the server uses other files. Invalid files are restored to their previous content.
+func (h *Handler) ApplyHba(hbaPath string, hbaContent string, identPath string, identContent string) (err error) {
+	conn := h.getPrimaryConnection()
+	defer conn.Close()
+	files := []*configFile{{path: hbaPath, setting: "hba_file", content: hbaContent}}
+	if identPath != "" {
+		files = append(files, &configFile{path: identPath, setting: "ident_file", content: identContent})
+	}
+	for _, file := range files {
+		if err = file.checkServerPath(conn); err != nil {
+			return err
+		}
+	}
+	reload := false
+	for _, file := range files {
+		if err = file.write(); err != nil {
+			return err
+		}
+		if !reload && !file.changed {
+			// an earlier run may have written the file, but failed before the reload
+			if reload, err = file.reloadPending(conn); err != nil {
+				return err
+			}
+		}
+		reload = reload || file.changed
+	}
+	if !reload {
+		log.Debugf("pg_hba.conf is up to date")
+		return nil
+	}
+	invalid, err := hbaErrors(conn)
+	if err != nil {
+		return err
+	}
+	if len(invalid) > 0 {
+		for _, file := range files {
+			if restoreErr := file.restore(); restoreErr != nil {
+				log.Errorf("Could not restore '%s': %v", file.path, restoreErr)
+			}
+		}
+		return fmt.Errorf("generated pg_hba.conf is invalid, previous version is restored: %s",
+			strings.Join(invalid, "; "))
+	}
+	if err = conn.runQueryExec("SELECT pg_reload_conf()"); err != nil {
+		return err
+	}
+	log.Infof("Configuration reloaded for the new pg_hba.conf")
+	return nil
+}
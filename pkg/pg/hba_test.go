@@ -0,0 +1,59 @@
+package pg
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/Hba", func() {
+	rules := []HbaRule{
+		{Type: "host", Databases: []string{"appdb"}, Users: []string{"app"}, Address: "all", Method: "md5"},
+		{Type: "local", Databases: []string{"all"}, Users: []string{"+admins"}, Method: "peer", Options: "map=os"},
+	}
+	Context("RenderHba", func() {
+		It("should replace the marker line in the template", func() {
+			template := "local all postgres peer\n" + HbaRulesMarker + "\nhost all all all reject\n"
+			Ω(RenderHba(template, rules)).To(Equal(hbaHeader + "\n" +
+				"local all postgres peer\n" +
+				"host\tappdb\tapp\tall\tmd5\n" +
+				"local\tall\t+admins\tpeer\tmap=os\n" +
+				"host all all all reject\n"))
+		})
+		It("should add the rules after a template without marker", func() {
+			Ω(RenderHba("local all postgres peer\n", rules[:1])).To(Equal(hbaHeader + "\n" +
+				"local all postgres peer\n" +
+				"host\tappdb\tapp\tall\tmd5\n"))
+		})
+	})
+	Context("RenderIdent", func() {
+		It("should render all mappings", func() {
+			Ω(RenderIdent([]IdentMapping{{Map: "pgfga", SystemUser: "cn", PgUser: "user"}})).To(Equal(
+				hbaHeader + "\npgfga\tcn\tuser\n"))
+		})
+	})
+	Context("configFile", func() {
+		It("should only write changed content, and restore the previous content", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "pg_hba.conf")
+			Ω(os.WriteFile(path, []byte("old\n"), hbaFileMode)).To(Succeed())
+			unchanged := configFile{path: path, content: "old\n"}
+			Ω(unchanged.write()).To(Succeed())
+			Ω(unchanged.changed).To(BeFalse())
+			changed := configFile{path: path, content: "new\n"}
+			Ω(changed.write()).To(Succeed())
+			Ω(changed.changed).To(BeTrue())
+			Ω(os.ReadFile(path)).To(Equal([]byte("new\n")))
+			Ω(changed.restore()).To(Succeed())
+			Ω(os.ReadFile(path)).To(Equal([]byte("old\n")))
+		})
+		It("should remove a file that did not exist before when restoring", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "pg_ident.conf")
+			created := configFile{path: path, content: "new\n"}
+			Ω(created.write()).To(Succeed())
+			Ω(created.restore()).To(Succeed())
+			Ω(path).NotTo(BeAnExistingFile())
+		})
+	})
+})
@@ -0,0 +1,239 @@
+package pg
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	inventorySchema = "pgfga"
+	inventoryTable  = inventorySchema + ".managed_objects"
+)
+
+// ObjectKind is the kind of object that is registered in the inventory
+type ObjectKind string
+
+const (
+	// ObjectKindRole is used for roles (and users)
+	ObjectKindRole ObjectKind = "role"
+	// ObjectKindDatabase is used for databases
+	ObjectKindDatabase ObjectKind = "database"
+	// ObjectKindSchema is used for schemas (which are registered with the database they are in)
+	ObjectKindSchema ObjectKind = "schema"
+	// ObjectKindSlot is used for replication slots
+	ObjectKindSlot ObjectKind = "replication_slot"
+)
+
+// ManagedObject is an object that was created (or adopted) by PgFga, as registered in the inventory
+type ManagedObject struct {
+	Kind ObjectKind
+	// Database is only set for objects that live in a database (schemas)
+	Database string
+	Name     string
+	// CreatedAt is the moment the object was registered, which is when it was adopted for adopted objects
+	CreatedAt time.Time
+	// Adopted means that the object already existed, and was registered because of strict.adopt_existing
+	Adopted bool
+}
+
+func (mo ManagedObject) String() string {
+	if mo.Database == "" {
+		return fmt.Sprintf("%s '%s'", mo.Kind, mo.Name)
+	}
+	return fmt.Sprintf("%s '%s'.'%s'", mo.Kind, mo.Database, mo.Name)
+}
+
+// inventory registers all objects that PgFga creates in a table in the default database, so that PgFga can be
+// restricted to only drop objects it created itself.
+type inventory struct {
+	conn        Conn
+	initialized bool
+	// adoptExisting registers existing objects that are defined in the config as well
+	adoptExisting bool
+}
+
+func newInventory(conn Conn) *inventory {
+	return &inventory{conn: conn}
+}
+
+// exists returns true if the inventory table exists
+func (inv *inventory) exists() (exists bool, err error) {
+	if inv.initialized {
+		return true, nil
+	}
+	return inv.conn.runQueryExists(
+		"SELECT relname FROM pg_class WHERE oid = to_regclass($1)",
+		inventoryTable)
+}
+
+// init creates the inventory table if it does not exist yet
+func (inv *inventory) init() (err error) {
+	if inv.initialized {
+		return nil
+	}
+	for _, query := range []string{
+		"CREATE SCHEMA IF NOT EXISTS " + identifier(inventorySchema),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			kind text NOT NULL,
+			database text NOT NULL DEFAULT '',
+			name text NOT NULL,
+			created_at timestamptz NOT NULL DEFAULT now(),
+			adopted boolean NOT NULL DEFAULT false,
+			PRIMARY KEY (kind, database, name))`, inventoryTable),
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS adopted boolean NOT NULL DEFAULT false", inventoryTable),
+	} {
+		if err = inv.conn.runQueryExec(query); err != nil {
+			return err
+		}
+	}
+	inv.initialized = true
+	return nil
+}
+
+// register adds an object to the inventory
+func (inv *inventory) register(obj ManagedObject) (err error) {
+	if err = inv.init(); err != nil {
+		return err
+	}
+	err = inv.conn.runQueryExec(
+		fmt.Sprintf(`INSERT INTO %s (kind, database, name) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			inventoryTable),
+		string(obj.Kind), obj.Database, obj.Name)
+	if err != nil {
+		return err
+	}
+	log.Debugf("Registered %s as managed object", obj)
+	return nil
+}
+
+// adopt adds an existing object to the inventory, and returns true if it was not registered yet
+func (inv *inventory) adopt(obj ManagedObject) (adopted bool, err error) {
+	if err = inv.init(); err != nil {
+		return false, err
+	}
+	return inv.conn.runQueryExists(
+		fmt.Sprintf(`INSERT INTO %s (kind, database, name, adopted) VALUES ($1, $2, $3, true)
+			ON CONFLICT DO NOTHING RETURNING name`, inventoryTable),
+		string(obj.Kind), obj.Database, obj.Name)
+}
+
+// unregister removes an object from the inventory
+func (inv *inventory) unregister(obj ManagedObject) (err error) {
+	if exists, err := inv.exists(); err != nil || !exists {
+		return err
+	}
+	return inv.conn.runQueryExec(
+		fmt.Sprintf("DELETE FROM %s WHERE kind = $1 AND database = $2 AND name = $3", inventoryTable),
+		string(obj.Kind), obj.Database, obj.Name)
+}
+
+// rename changes the name of a registered object
+func (inv *inventory) rename(obj ManagedObject, newName string) (err error) {
+	if exists, err := inv.exists(); err != nil || !exists {
+		return err
+	}
+	return inv.conn.runQueryExec(
+		fmt.Sprintf("UPDATE %s SET name = $4 WHERE kind = $1 AND database = $2 AND name = $3", inventoryTable),
+		string(obj.Kind), obj.Database, obj.Name, newName)
+}
+
+// isManaged returns true if the object is registered in the inventory
+func (inv *inventory) isManaged(obj ManagedObject) (managed bool, err error) {
+	if exists, err := inv.exists(); err != nil || !exists {
+		return false, err
+	}
+	return inv.conn.runQueryExists(
+		fmt.Sprintf("SELECT name FROM %s WHERE kind = $1 AND database = $2 AND name = $3", inventoryTable),
+		string(obj.Kind), obj.Database, obj.Name)
+}
+
+// list returns all objects in the inventory
+func (inv *inventory) list() (objects []ManagedObject, err error) {
+	if exists, err := inv.exists(); err != nil || !exists {
+		return nil, err
+	}
+	if err = inv.conn.Connect(); err != nil {
+		return nil, err
+	}
+	rows, err := inv.conn.conn.Query(inv.conn.ctx,
+		fmt.Sprintf("SELECT kind, database, name, created_at, adopted FROM %s ORDER BY kind, database, name",
+			inventoryTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var obj ManagedObject
+		var kind string
+		if err = rows.Scan(&kind, &obj.Database, &obj.Name, &obj.CreatedAt, &obj.Adopted); err != nil {
+			return nil, err
+		}
+		obj.Kind = ObjectKind(kind)
+		objects = append(objects, obj)
+	}
+	return objects, rows.Err()
+}
+
+// registerManaged registers a newly created object in the inventory (when this connection has one)
+func (c *Conn) registerManaged(obj ManagedObject) error {
+	if c.inventory == nil {
+		return nil
+	}
+	return c.inventory.register(obj)
+}
+
+// adoptManaged registers an existing object that is defined in the config in the inventory (when this connection has
+// one, and adopt_existing is set), so that objects that existed before managed_only was enabled can be dropped when
+// they are set to Absent later
+func (c *Conn) adoptManaged(obj ManagedObject) error {
+	if c.inventory == nil || !c.inventory.adoptExisting {
+		return nil
+	}
+	adopted, err := c.inventory.adopt(obj)
+	if err != nil {
+		return err
+	}
+	if adopted {
+		log.Infof("Adopted existing %s as managed object", obj)
+	}
+	return nil
+}
+
+// unregisterManaged removes a dropped object from the inventory (when this connection has one)
+func (c *Conn) unregisterManaged(obj ManagedObject) error {
+	if c.inventory == nil {
+		return nil
+	}
+	return c.inventory.unregister(obj)
+}
+
+// renameManaged changes the name of a renamed object in the inventory (when this connection has one)
+func (c *Conn) renameManaged(obj ManagedObject, newName string) error {
+	if c.inventory == nil {
+		return nil
+	}
+	return c.inventory.rename(obj, newName)
+}
+
+// mayDrop returns true if an object may be dropped. When this connection has an inventory, only objects that are
+// registered as managed may be dropped.
+func (c *Conn) mayDrop(obj ManagedObject) (mayDrop bool, err error) {
+	if c.inventory == nil {
+		return true, nil
+	}
+	managed, err := c.inventory.isManaged(obj)
+	if err != nil {
+		return false, err
+	}
+	if !managed {
+		log.Warnf("Not dropping %s, because it is not registered as managed by pgfga", obj)
+	}
+	return managed, nil
+}
+
+// ManagedObjects returns all objects that are registered as created by PgFga
+func (h *Handler) ManagedObjects() (objects []ManagedObject, err error) {
+	inv := newInventory(h.getPrimaryConnection())
+	defer inv.conn.Close()
+	return inv.list()
+}
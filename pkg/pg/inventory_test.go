@@ -0,0 +1,41 @@
+package pg
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/Inventory", func() {
+	Context("ManagedObject", func() {
+		It("should describe the object", func() {
+			Ω(ManagedObject{Kind: ObjectKindRole, Name: "app"}.String()).To(Equal("role 'app'"))
+			Ω(ManagedObject{Kind: ObjectKindSchema, Database: "appdb", Name: "app"}.String()).To(
+				Equal("schema 'appdb'.'app'"))
+		})
+	})
+	Context("without inventory", func() {
+		conn := NewConn(ConnParams{})
+		obj := ManagedObject{Kind: ObjectKindRole, Name: "app"}
+		It("should allow dropping all objects", func() {
+			Ω(conn.mayDrop(obj)).To(BeTrue())
+		})
+		It("should not register objects", func() {
+			Ω(conn.registerManaged(obj)).To(Succeed())
+			Ω(conn.adoptManaged(obj)).To(Succeed())
+			Ω(conn.unregisterManaged(obj)).To(Succeed())
+		})
+		It("should keep the inventory when switching databases", func() {
+			inv := newInventory(conn)
+			Ω(conn.withInventory(inv).SwitchDB("other").inventory).To(BeIdenticalTo(inv))
+		})
+	})
+	Context("with inventory", func() {
+		It("should only adopt existing objects with adopt_existing", func() {
+			conn := NewConn(ConnParams{"host": "/nonexistent"})
+			conn = conn.withInventory(newInventory(conn))
+			Ω(conn.adoptManaged(ManagedObject{Kind: ObjectKindRole, Name: "app"})).To(Succeed())
+			conn.inventory.adoptExisting = true
+			Ω(conn.adoptManaged(ManagedObject{Kind: ObjectKindRole, Name: "app"})).NotTo(Succeed())
+		})
+	})
+})
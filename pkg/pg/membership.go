@@ -0,0 +1,91 @@
+package pg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Membership defines a role that is granted to another role, with optional grant options.
+// Options that are not set (nil) are not managed.
+type Membership struct {
+	Role string `yaml:"role"`
+	// Admin allows the member to grant this role to others (WITH ADMIN OPTION)
+	Admin *bool `yaml:"admin"`
+	// Inherit (16+) defines if the member inherits the privileges of this role
+	Inherit *bool `yaml:"inherit"`
+	// Set (16+) defines if the member can SET ROLE to this role
+	Set *bool `yaml:"set"`
+}
+
+// UnmarshalYAML allows a membership to be set as a plain role name or as a map with grant options
+func (m *Membership) UnmarshalYAML(unmarshal func(any) error) error {
+	var role string
+	if err := unmarshal(&role); err == nil {
+		*m = Membership{Role: role}
+		return nil
+	}
+	type plain Membership
+	if err := unmarshal((*plain)(m)); err != nil {
+		return err
+	}
+	if m.Role == "" {
+		return errors.New("role must be set for a membership")
+	}
+	return nil
+}
+
+// grantOption is one of the options that can be set on a role membership
+type grantOption string
+
+const (
+	grantOptionAdmin   grantOption = "ADMIN"
+	grantOptionInherit grantOption = "INHERIT"
+	grantOptionSet     grantOption = "SET"
+)
+
+// pg16VersionNum is the first version where INHERIT and SET are options of a grant, and where memberships are
+// registered per grantor
+const pg16VersionNum = 160000
+
+// grantOptions holds the options of a role membership. Options that are nil are not managed.
+type grantOptions map[grantOption]*bool
+
+// supported returns only the options that are supported by a PostgreSQL server version
+func (gos grantOptions) supported(serverVersion int) grantOptions {
+	supported := grantOptions{}
+	for option, value := range gos {
+		if value == nil {
+			continue
+		}
+		if option != grantOptionAdmin && serverVersion < pg16VersionNum {
+			log.Warnf("grant option %s is only supported on PostgreSQL 16 and newer, ignoring", option)
+			continue
+		}
+		supported[option] = value
+	}
+	return supported
+}
+
+// withSQL returns the WITH part of a GRANT statement for all options (e.a. ` WITH ADMIN TRUE, INHERIT FALSE`)
+func (gos grantOptions) withSQL(serverVersion int) string {
+	var options []string
+	for _, option := range []grantOption{grantOptionAdmin, grantOptionInherit, grantOptionSet} {
+		value, exists := gos[option]
+		if !exists || value == nil {
+			continue
+		}
+		if serverVersion < pg16VersionNum {
+			// Before 16, only ADMIN OPTION exists, and it can only be enabled
+			if option == grantOptionAdmin && *value {
+				options = append(options, "ADMIN OPTION")
+			}
+			continue
+		}
+		options = append(options, fmt.Sprintf("%s %s", option, strings.ToUpper(fmt.Sprint(*value))))
+	}
+	if len(options) == 0 {
+		return ""
+	}
+	return " WITH " + strings.Join(options, ", ")
+}
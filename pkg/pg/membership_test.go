@@ -0,0 +1,41 @@
+package pg
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+var _ = Describe("Pkg/Pg/Membership", func() {
+	Context("UnmarshalYAML", func() {
+		It("should accept role names and maps with grant options", func() {
+			var memberships []Membership
+			Ω(yaml.Unmarshal([]byte("- app_read\n- role: app_owner\n  admin: true\n  set: false\n"),
+				&memberships)).To(Succeed())
+			Ω(memberships).To(HaveLen(2))
+			Ω(memberships[0]).To(Equal(Membership{Role: "app_read"}))
+			Ω(memberships[1].Role).To(Equal("app_owner"))
+			Ω(*memberships[1].Admin).To(BeTrue())
+			Ω(memberships[1].Inherit).To(BeNil())
+			Ω(*memberships[1].Set).To(BeFalse())
+		})
+		It("should require a role", func() {
+			var memberships []Membership
+			Ω(yaml.Unmarshal([]byte("- admin: true\n"), &memberships)).NotTo(Succeed())
+		})
+	})
+	Context("grantOptions", func() {
+		yes, no := true, false
+		options := grantOptions{grantOptionAdmin: &yes, grantOptionInherit: &no, grantOptionSet: nil}
+		It("should only return options supported by the server version", func() {
+			Ω(options.supported(pg16VersionNum)).To(HaveLen(2))
+			Ω(options.supported(150000)).To(Equal(grantOptions{grantOptionAdmin: &yes}))
+		})
+		It("should build the WITH part of a GRANT statement", func() {
+			Ω(options.withSQL(pg16VersionNum)).To(Equal(" WITH ADMIN TRUE, INHERIT FALSE"))
+			Ω(options.withSQL(150000)).To(Equal(" WITH ADMIN OPTION"))
+			Ω(grantOptions{grantOptionAdmin: &no}.withSQL(150000)).To(BeEmpty())
+			Ω(grantOptions{}.withSQL(pg16VersionNum)).To(BeEmpty())
+		})
+	})
+})
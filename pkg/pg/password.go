@@ -0,0 +1,189 @@
+package pg
+
+import (
+	"crypto/hmac"
+	// md5 is weak, but it is still an accepted password algorithm in Postgres.
+	// #nosec
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	md5PasswordLength = 35
+	md5PasswordPrefix = "md5"
+
+	scramPrefix          = "SCRAM-SHA-256"
+	scramIterations      = 4096
+	scramSaltLength      = 16
+	scramKeyLength       = sha256.Size
+	scramClientKeyString = "Client Key"
+	scramServerKeyString = "Server Key"
+)
+
+// PasswordEncryption is the algorithm used to hash a password before it is stored in PostgreSQL
+type PasswordEncryption string
+
+const (
+	// PasswordEncryptionMD5 stores passwords as md5 hash (deprecated in recent PostgreSQL versions)
+	PasswordEncryptionMD5 PasswordEncryption = "md5"
+	// PasswordEncryptionScram stores passwords as SCRAM-SHA-256 verifier
+	PasswordEncryptionScram PasswordEncryption = "scram-sha-256"
+)
+
+// Validate will check if this is a valid password encryption and return an error if it isn't
+func (pe PasswordEncryption) Validate() error {
+	switch pe {
+	case PasswordEncryptionMD5, PasswordEncryptionScram:
+		return nil
+	}
+	return fmt.Errorf("%s is not a valid password encryption (should be md5 or scram-sha-256)", pe)
+}
+
+// isMD5Hash returns true if the password is a precomputed md5 hash
+func isMD5Hash(password string) bool {
+	return len(password) == md5PasswordLength && strings.HasPrefix(password, md5PasswordPrefix)
+}
+
+// md5Hash returns the md5 hash for a user and a password as PostgreSQL stores it
+func md5Hash(userName string, password string) string {
+	// #nosec
+	return fmt.Sprintf("%s%x", md5PasswordPrefix, md5.Sum([]byte(password+userName)))
+}
+
+// scramVerifier holds all parts of a SCRAM-SHA-256 verifier as PostgreSQL stores it
+type scramVerifier struct {
+	iterations int
+	salt       []byte
+	storedKey  []byte
+	serverKey  []byte
+}
+
+// isScramVerifier returns true if the password is a precomputed SCRAM-SHA-256 verifier
+func isScramVerifier(password string) bool {
+	_, err := parseScramVerifier(password)
+	return err == nil
+}
+
+// parseScramVerifier parses a verifier in the format SCRAM-SHA-256$<iterations>:<salt>$<StoredKey>:<ServerKey>
+func parseScramVerifier(verifier string) (sv scramVerifier, err error) {
+	parts := strings.Split(verifier, "$")
+	if len(parts) != 3 || parts[0] != scramPrefix {
+		return sv, errors.New("not a SCRAM-SHA-256 verifier")
+	}
+	iterations, salt, found := strings.Cut(parts[1], ":")
+	if !found {
+		return sv, errors.New("SCRAM-SHA-256 verifier without salt")
+	}
+	storedKey, serverKey, found := strings.Cut(parts[2], ":")
+	if !found {
+		return sv, errors.New("SCRAM-SHA-256 verifier without server key")
+	}
+	if sv.iterations, err = strconv.Atoi(iterations); err != nil {
+		return sv, fmt.Errorf("invalid iterations in SCRAM-SHA-256 verifier: %w", err)
+	}
+	for _, part := range []struct {
+		encoded string
+		decoded *[]byte
+	}{
+		{encoded: salt, decoded: &sv.salt},
+		{encoded: storedKey, decoded: &sv.storedKey},
+		{encoded: serverKey, decoded: &sv.serverKey},
+	} {
+		if *part.decoded, err = base64.StdEncoding.DecodeString(part.encoded); err != nil {
+			return sv, fmt.Errorf("invalid base64 in SCRAM-SHA-256 verifier: %w", err)
+		}
+	}
+	return sv, nil
+}
+
+func scramHMAC(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// newScramVerifier computes a SCRAM-SHA-256 verifier for a password with a specific salt and iteration count
+func newScramVerifier(password string, salt []byte, iterations int) (sv scramVerifier, err error) {
+	saltedPassword, err := pbkdf2.Key(sha256.New, password, salt, iterations, scramKeyLength)
+	if err != nil {
+		return sv, err
+	}
+	storedKey := sha256.Sum256(scramHMAC(saltedPassword, scramClientKeyString))
+	return scramVerifier{
+		iterations: iterations,
+		salt:       salt,
+		storedKey:  storedKey[:],
+		serverKey:  scramHMAC(saltedPassword, scramServerKeyString),
+	}, nil
+}
+
+// newRandomScramVerifier computes a SCRAM-SHA-256 verifier for a password with a new random salt
+func newRandomScramVerifier(password string) (sv scramVerifier, err error) {
+	salt := make([]byte, scramSaltLength)
+	if _, err = rand.Read(salt); err != nil {
+		return sv, err
+	}
+	return newScramVerifier(password, salt, scramIterations)
+}
+
+// verify checks if the password matches this verifier (using the salt and iterations of this verifier)
+func (sv scramVerifier) verify(password string) bool {
+	other, err := newScramVerifier(password, sv.salt, sv.iterations)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(sv.storedKey, other.storedKey) && hmac.Equal(sv.serverKey, other.serverKey)
+}
+
+func (sv scramVerifier) String() string {
+	return fmt.Sprintf("%s$%d:%s$%s:%s",
+		scramPrefix,
+		sv.iterations,
+		base64.StdEncoding.EncodeToString(sv.salt),
+		base64.StdEncoding.EncodeToString(sv.storedKey),
+		base64.StdEncoding.EncodeToString(sv.serverKey),
+	)
+}
+
+// passwordMatches checks if a password from config matches the (hashed) password as stored in PostgreSQL.
+// Precomputed hashes and verifiers are compared as is, cleartext passwords are hashed with the requested algorithm.
+func passwordMatches(userName string, password string, encryption PasswordEncryption, stored string) bool {
+	if isMD5Hash(password) || isScramVerifier(password) {
+		return password == stored
+	}
+	switch encryption {
+	case PasswordEncryptionScram:
+		sv, err := parseScramVerifier(stored)
+		if err != nil {
+			return false
+		}
+		return sv.verify(password)
+	default:
+		return md5Hash(userName, password) == stored
+	}
+}
+
+// hashPassword returns the value to be stored in PostgreSQL for a password from config.
+// Precomputed hashes and verifiers are returned as is, cleartext passwords are hashed with the requested algorithm.
+func hashPassword(userName string, password string, encryption PasswordEncryption) (hashed string, err error) {
+	if isMD5Hash(password) || isScramVerifier(password) {
+		return password, nil
+	}
+	switch encryption {
+	case PasswordEncryptionScram:
+		sv, err := newRandomScramVerifier(password)
+		if err != nil {
+			return "", err
+		}
+		return sv.String(), nil
+	default:
+		return md5Hash(userName, password), nil
+	}
+}
@@ -0,0 +1,75 @@
+package pg
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/Password", func() {
+	const (
+		userName  = "backup_user"
+		password  = "bckpa$$w0rd"
+		md5Hashed = "md5f713f6e7f14c80ea919f5d5bdd5b67cf"
+		salt      = "0123456789abcdef"
+		//revive:disable-next-line
+		verifier = "SCRAM-SHA-256$4096:MDEyMzQ1Njc4OWFiY2RlZg==$KrwyY+HtRrHXFA9BMnaKkX8vPFJOG9cWDOQCocZ8Ek8=:cjEaYq1BZfskmYEaa2V+LXJ8WEgSx0tLWj86fo+tJfk="
+	)
+	Context("PasswordEncryption", func() {
+		It("should validate", func() {
+			Ω(PasswordEncryptionMD5.Validate()).NotTo(HaveOccurred())
+			Ω(PasswordEncryptionScram.Validate()).NotTo(HaveOccurred())
+			Ω(PasswordEncryption("sha1").Validate()).To(HaveOccurred())
+		})
+	})
+	Context("md5", func() {
+		It("should hash as PostgreSQL does", func() {
+			Ω(md5Hash(userName, password)).To(Equal(md5Hashed))
+			Ω(isMD5Hash(md5Hashed)).To(BeTrue())
+			Ω(isMD5Hash(password)).To(BeFalse())
+		})
+	})
+	Context("scram", func() {
+		It("should compute verifiers as PostgreSQL does", func() {
+			sv, err := newScramVerifier(password, []byte(salt), scramIterations)
+			Ω(err).NotTo(HaveOccurred())
+			Ω(sv.String()).To(Equal(verifier))
+		})
+		It("should parse and verify verifiers", func() {
+			sv, err := parseScramVerifier(verifier)
+			Ω(err).NotTo(HaveOccurred())
+			Ω(sv.iterations).To(Equal(scramIterations))
+			Ω(sv.verify(password)).To(BeTrue())
+			Ω(sv.verify("wrong")).To(BeFalse())
+			Ω(isScramVerifier(verifier)).To(BeTrue())
+			Ω(isScramVerifier(password)).To(BeFalse())
+			Ω(isScramVerifier("SCRAM-SHA-256$4096:salt")).To(BeFalse())
+		})
+		It("should generate random verifiers that can be verified", func() {
+			hashed, err := hashPassword(userName, password, PasswordEncryptionScram)
+			Ω(err).NotTo(HaveOccurred())
+			Ω(hashed).NotTo(Equal(verifier))
+			Ω(passwordMatches(userName, password, PasswordEncryptionScram, hashed)).To(BeTrue())
+		})
+	})
+	Context("passwordMatches", func() {
+		It("should compare passwords with what is stored in PostgreSQL", func() {
+			tests := []struct {
+				password   string
+				encryption PasswordEncryption
+				stored     string
+				expected   bool
+			}{
+				{password: password, encryption: PasswordEncryptionMD5, stored: md5Hashed, expected: true},
+				{password: password, encryption: PasswordEncryptionMD5, stored: verifier, expected: false},
+				{password: password, encryption: PasswordEncryptionScram, stored: verifier, expected: true},
+				{password: password, encryption: PasswordEncryptionScram, stored: md5Hashed, expected: false},
+				{password: md5Hashed, encryption: PasswordEncryptionScram, stored: md5Hashed, expected: true},
+				{password: verifier, encryption: PasswordEncryptionMD5, stored: verifier, expected: true},
+				{password: password, encryption: PasswordEncryptionScram, stored: "", expected: false},
+			}
+			for _, test := range tests {
+				Ω(passwordMatches(userName, test.password, test.encryption, test.stored)).To(Equal(test.expected))
+			}
+		})
+	})
+})
@@ -0,0 +1,372 @@
+package pg
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// pg15VersionNum is the first version with row filters and FOR TABLES IN SCHEMA for publications
+const pg15VersionNum = 150000
+
+// allPublishOperations are the operations that are published by default
+var allPublishOperations = []string{"insert", "update", "delete", "truncate"}
+
+// Publications is a map of all publications in a database, where the key is the name of the publication
+type Publications map[string]Publication
+
+// reconcile creates, alters and drops all publications
+func (ps Publications) reconcile(dbConn *Conn) (err error) {
+	for _, pubName := range slices.Sorted(maps.Keys(ps)) {
+		pub := ps[pubName]
+		pub.name = pubName
+		if err = pub.reconcile(dbConn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublicationTable holds the options of a table in a publication
+type PublicationTable struct {
+	// Where is a row filter (e.a. region = 'eu'), which requires PostgreSQL 15 or newer
+	Where string `yaml:"where"`
+}
+
+// PublicationTables is a map of tables in a publication, where the key is the (schema qualified) name of the table
+type PublicationTables map[string]PublicationTable
+
+// UnmarshalYAML allows tables to be set as a list of table names, or as a map with options per table
+func (pt *PublicationTables) UnmarshalYAML(unmarshal func(any) error) error {
+	var names []string
+	if err := unmarshal(&names); err == nil {
+		*pt = PublicationTables{}
+		for _, name := range names {
+			(*pt)[name] = PublicationTable{}
+		}
+		return nil
+	}
+	type plain PublicationTables
+	return unmarshal((*plain)(pt))
+}
+
+// qualified returns the tables with schema qualified names (tables without a schema are in public)
+func (pt PublicationTables) qualified() PublicationTables {
+	qualified := PublicationTables{}
+	for name, table := range pt {
+		if !strings.Contains(name, ".") {
+			name = "public." + name
+		}
+		qualified[name] = table
+	}
+	return qualified
+}
+
+// rowFilterTable is the temporary table that serverRowFilter uses to let PostgreSQL parse a row filter
+const rowFilterTable = "pgfga_row_filter"
+
+// normalized returns the tables with schema qualified names, and with row filters as PostgreSQL stores them (see
+// serverRowFilter), so that they can be compared to the tables that are in the publication
+func (pt PublicationTables) normalized(conn *Conn) (normalized PublicationTables, err error) {
+	normalized = PublicationTables{}
+	for name, table := range pt.qualified() {
+		if table.Where, err = serverRowFilter(conn, name, table.Where); err != nil {
+			return nil, err
+		}
+		normalized[name] = table
+	}
+	return normalized, nil
+}
+
+// serverRowFilter returns a row filter for a table like pg_get_expr returns it for a publication (e.a. region = 'eu'
+// becomes (region = 'eu'::text)). PostgreSQL parses the row filter as a check constraint on a temporary copy of the
+// table, which is deparsed the same way.
+func serverRowFilter(conn *Conn, tableName string, where string) (normalized string, err error) {
+	if where == "" {
+		return "", nil
+	}
+	for _, query := range []string{
+		"DROP TABLE IF EXISTS pg_temp." + identifier(rowFilterTable),
+		fmt.Sprintf("CREATE TEMPORARY TABLE %s (LIKE %s)", identifier(rowFilterTable), qualifiedIdentifier(tableName)),
+		fmt.Sprintf("ALTER TABLE pg_temp.%s ADD CHECK (%s)", identifier(rowFilterTable), where),
+	} {
+		if err = conn.runQueryExec(query); err != nil {
+			return "", fmt.Errorf("invalid row filter for table %s: %w", tableName, err)
+		}
+	}
+	normalized, err = conn.runQueryGetOneField(
+		`SELECT pg_get_expr(conbin, conrelid) FROM pg_constraint
+		WHERE conrelid = to_regclass('pg_temp.' || quote_ident($1)) AND contype = 'c'`,
+		rowFilterTable)
+	if err != nil {
+		return "", err
+	}
+	return normalized, conn.runQueryExec("DROP TABLE pg_temp." + identifier(rowFilterTable))
+}
+
+// qualifiedIdentifier quotes a (schema qualified) name of a table
+func qualifiedIdentifier(name string) string {
+	var parts []string
+	for part := range strings.SplitSeq(name, ".") {
+		parts = append(parts, identifier(part))
+	}
+	return strings.Join(parts, ".")
+}
+
+// Publication defines a publication for logical replication
+type Publication struct {
+	name  string
+	State State `yaml:"state"`
+	// AllTables publishes all tables in the database (FOR ALL TABLES)
+	AllTables bool              `yaml:"all_tables"`
+	Tables    PublicationTables `yaml:"tables"`
+	// Schemas publishes all tables in these schemas (FOR TABLES IN SCHEMA), which requires PostgreSQL 15 or newer
+	Schemas []string `yaml:"schemas"`
+	// Publish are the operations that are published (defaults to insert, update, delete and truncate)
+	Publish []string `yaml:"publish"`
+}
+
+// validate checks the definition of the publication against the version of the server
+func (p Publication) validate(serverVersion int) error {
+	if p.AllTables && (len(p.Tables) > 0 || len(p.Schemas) > 0) {
+		return fmt.Errorf("publication %s cannot have tables or schemas when all_tables is set", p.name)
+	}
+	for _, operation := range p.Publish {
+		if !slices.Contains(allPublishOperations, operation) {
+			return fmt.Errorf("publication %s has invalid publish operation %s (should be one of %s)",
+				p.name, operation, strings.Join(allPublishOperations, ", "))
+		}
+	}
+	if serverVersion >= pg15VersionNum {
+		return nil
+	}
+	if len(p.Schemas) > 0 {
+		return fmt.Errorf("publication %s: schemas require PostgreSQL 15 or newer", p.name)
+	}
+	for _, table := range p.Tables {
+		if table.Where != "" {
+			return fmt.Errorf("publication %s: row filters require PostgreSQL 15 or newer", p.name)
+		}
+	}
+	return nil
+}
+
+// publish returns the sorted operations that should be published
+func (p Publication) publish() []string {
+	if len(p.Publish) == 0 {
+		return allPublishOperations
+	}
+	var publish []string
+	for _, operation := range allPublishOperations {
+		if slices.Contains(p.Publish, operation) {
+			publish = append(publish, operation)
+		}
+	}
+	return publish
+}
+
+// objectsSQL returns the tables and schemas of the publication (e.a. TABLE "public"."t" WHERE (a > 1), TABLES IN
+// SCHEMA "s"). Before PostgreSQL 15 a publication can only hold tables, which are listed after a single TABLE.
+func objectsSQL(tables PublicationTables, schemas []string, serverVersion int) string {
+	var objects []string
+	tables = tables.qualified()
+	for _, tableName := range slices.Sorted(maps.Keys(tables)) {
+		object := qualifiedIdentifier(tableName)
+		if serverVersion >= pg15VersionNum {
+			object = "TABLE " + object
+		}
+		if where := tables[tableName].Where; where != "" {
+			object += fmt.Sprintf(" WHERE (%s)", where)
+		}
+		objects = append(objects, object)
+	}
+	if serverVersion < pg15VersionNum {
+		if len(objects) == 0 {
+			return ""
+		}
+		return "TABLE " + strings.Join(objects, ", ")
+	}
+	for _, schema := range slices.Sorted(slices.Values(schemas)) {
+		objects = append(objects, "TABLES IN SCHEMA "+identifier(schema))
+	}
+	return strings.Join(objects, ", ")
+}
+
+func (p Publication) exists(conn *Conn) (exists bool, err error) {
+	return conn.runQueryExists("SELECT pubname FROM pg_publication WHERE pubname = $1", p.name)
+}
+
+func (p Publication) reconcile(conn *Conn) (err error) {
+	if p.State != Present {
+		return p.drop(conn)
+	}
+	serverVersion, err := conn.serverVersion()
+	if err != nil {
+		return err
+	}
+	if err = p.validate(serverVersion); err != nil {
+		return err
+	}
+	exists, err := p.exists(conn)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return p.create(conn, serverVersion)
+	}
+	for _, recFunc := range []func(*Conn, int) error{
+		p.reconcileAllTables,
+		p.reconcilePublish,
+		p.reconcileObjects,
+	} {
+		if err = recFunc(conn, serverVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Publication) create(conn *Conn, serverVersion int) (err error) {
+	createQry := "CREATE PUBLICATION " + identifier(p.name)
+	if p.AllTables {
+		createQry += " FOR ALL TABLES"
+	} else if objects := objectsSQL(p.Tables, p.Schemas, serverVersion); objects != "" {
+		createQry += " FOR " + objects
+	}
+	createQry += fmt.Sprintf(" WITH (publish = %s)", quotedSQLValue(strings.Join(p.publish(), ", ")))
+	if err = conn.runQueryExec(createQry); err != nil {
+		return err
+	}
+	log.Infof("Publication '%s'.'%s' successfully created", conn.DBName(), p.name)
+	return nil
+}
+
+func (p Publication) drop(conn *Conn) (err error) {
+	exists, err := p.exists(conn)
+	if err != nil || !exists {
+		return err
+	}
+	if err = conn.runQueryExec("DROP PUBLICATION " + identifier(p.name)); err != nil {
+		return err
+	}
+	log.Infof("Publication '%s'.'%s' successfully dropped", conn.DBName(), p.name)
+	return nil
+}
+
+// reconcileAllTables returns an error when all_tables differs, since FOR ALL TABLES cannot be altered
+func (p Publication) reconcileAllTables(conn *Conn, _ int) (err error) {
+	allTables, err := conn.runQueryExists(
+		"SELECT pubname FROM pg_publication WHERE pubname = $1 AND puballtables", p.name)
+	if err != nil {
+		return err
+	}
+	if allTables != p.AllTables {
+		return fmt.Errorf("publication %s in database %s has all_tables %t instead of %t, and should be recreated "+
+			"manually", p.name, conn.DBName(), allTables, p.AllTables)
+	}
+	return nil
+}
+
+func (p Publication) reconcilePublish(conn *Conn, _ int) (err error) {
+	current, err := conn.runQueryGetOneColumn(
+		`SELECT operation FROM pg_publication,
+		LATERAL (VALUES ('insert', pubinsert), ('update', pubupdate), ('delete', pubdelete),
+			('truncate', pubtruncate)) AS operations(operation, published)
+		WHERE pubname = $1 AND published`,
+		p.name)
+	if err != nil {
+		return err
+	}
+	publish := p.publish()
+	if slices.Equal(slices.Sorted(slices.Values(current)), slices.Sorted(slices.Values(publish))) {
+		return nil
+	}
+	publishStr := strings.Join(publish, ", ")
+	err = conn.runQueryExec(fmt.Sprintf("ALTER PUBLICATION %s SET (publish = %s)",
+		identifier(p.name), quotedSQLValue(publishStr)))
+	if err != nil {
+		return err
+	}
+	log.Infof("Publication '%s'.'%s' now publishes %s", conn.DBName(), p.name, publishStr)
+	return nil
+}
+
+// currentTables returns the tables (with their row filters) that are in the publication
+func (p Publication) currentTables(conn *Conn, serverVersion int) (tables PublicationTables, err error) {
+	where := "''"
+	if serverVersion >= pg15VersionNum {
+		where = "coalesce(pg_get_expr(pr.prqual, pr.prrelid), '')"
+	}
+	rows, err := conn.runQueryGetOneColumn(fmt.Sprintf(
+		`SELECT n.nspname || '.' || c.relname || E'\t' || %s
+		FROM pg_publication_rel pr
+		INNER JOIN pg_publication p ON pr.prpubid = p.oid
+		INNER JOIN pg_class c ON pr.prrelid = c.oid
+		INNER JOIN pg_namespace n ON c.relnamespace = n.oid
+		WHERE p.pubname = $1`, where),
+		p.name)
+	if err != nil {
+		return nil, err
+	}
+	tables = PublicationTables{}
+	for _, row := range rows {
+		name, where, _ := strings.Cut(row, "\t")
+		tables[name] = PublicationTable{Where: where}
+	}
+	return tables, nil
+}
+
+// currentSchemas returns the schemas that are in the publication
+func (p Publication) currentSchemas(conn *Conn, serverVersion int) (schemas []string, err error) {
+	if serverVersion < pg15VersionNum {
+		return nil, nil
+	}
+	return conn.runQueryGetOneColumn(
+		`SELECT n.nspname FROM pg_publication_namespace pn
+		INNER JOIN pg_publication p ON pn.pnpubid = p.oid
+		INNER JOIN pg_namespace n ON pn.pnnspid = n.oid
+		WHERE p.pubname = $1
+		ORDER BY n.nspname`,
+		p.name)
+}
+
+// reconcileObjects sets the tables and schemas of the publication when they differ from the definition
+func (p Publication) reconcileObjects(conn *Conn, serverVersion int) (err error) {
+	if p.AllTables {
+		return nil
+	}
+	currentTables, err := p.currentTables(conn, serverVersion)
+	if err != nil {
+		return err
+	}
+	currentSchemas, err := p.currentSchemas(conn, serverVersion)
+	if err != nil {
+		return err
+	}
+	tables, err := p.Tables.normalized(conn)
+	if err != nil {
+		return err
+	}
+	if maps.Equal(currentTables, tables) &&
+		slices.Equal(currentSchemas, slices.Sorted(slices.Values(p.Schemas))) {
+		return nil
+	}
+	objects := objectsSQL(p.Tables, p.Schemas, serverVersion)
+	if objects == "" {
+		// SET requires at least one object, so all tables and schemas are dropped from the publication instead
+		dropped := PublicationTables{}
+		for tableName := range currentTables {
+			dropped[tableName] = PublicationTable{}
+		}
+		err = conn.runQueryExec(fmt.Sprintf("ALTER PUBLICATION %s DROP %s",
+			identifier(p.name), objectsSQL(dropped, currentSchemas, serverVersion)))
+	} else {
+		err = conn.runQueryExec(fmt.Sprintf("ALTER PUBLICATION %s SET %s", identifier(p.name), objects))
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("Publication '%s'.'%s' successfully set to %s", conn.DBName(), p.name, objects)
+	return nil
+}
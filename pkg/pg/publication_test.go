@@ -0,0 +1,56 @@
+package pg
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+var _ = Describe("Pkg/Pg/Publication", func() {
+	Context("PublicationTables", func() {
+		It("should unmarshal a list of tables and a map of tables", func() {
+			var tables PublicationTables
+			Ω(yaml.Unmarshal([]byte("[orders, sales.invoices]"), &tables)).To(Succeed())
+			Ω(tables).To(Equal(PublicationTables{"orders": {}, "sales.invoices": {}}))
+			var filtered PublicationTables
+			Ω(yaml.Unmarshal([]byte("orders: {where: \"region = 'eu'\"}"), &filtered)).To(Succeed())
+			Ω(filtered).To(Equal(PublicationTables{"orders": {Where: "region = 'eu'"}}))
+		})
+		It("should qualify tables without a schema", func() {
+			tables := PublicationTables{"orders": {Where: "region = 'eu'"}, "sales.invoices": {}}
+			Ω(tables.qualified()).To(Equal(PublicationTables{
+				"public.orders":  {Where: "region = 'eu'"},
+				"sales.invoices": {},
+			}))
+		})
+	})
+	Context("objectsSQL", func() {
+		tables := PublicationTables{"orders": {Where: "region = 'eu'"}, "sales.invoices": {}}
+		It("should list tables and schemas", func() {
+			Ω(objectsSQL(tables, []string{"audit"}, pg15VersionNum)).To(Equal(
+				`TABLE "public"."orders" WHERE (region = 'eu'), TABLE "sales"."invoices", TABLES IN SCHEMA "audit"`))
+		})
+		It("should only list tables before PostgreSQL 15", func() {
+			Ω(objectsSQL(PublicationTables{"orders": {}, "sales.invoices": {}}, nil, pg14VersionNum)).To(Equal(
+				`TABLE "public"."orders", "sales"."invoices"`))
+			Ω(objectsSQL(nil, nil, pg14VersionNum)).To(BeEmpty())
+		})
+	})
+	Context("validate", func() {
+		It("should check the definition against the server version", func() {
+			Ω(Publication{AllTables: true}.validate(pg14VersionNum)).To(Succeed())
+			Ω(Publication{AllTables: true, Schemas: []string{"audit"}}.validate(pg15VersionNum)).NotTo(Succeed())
+			Ω(Publication{Publish: []string{"select"}}.validate(pg15VersionNum)).NotTo(Succeed())
+			Ω(Publication{Schemas: []string{"audit"}}.validate(pg14VersionNum)).NotTo(Succeed())
+			filtered := Publication{Tables: PublicationTables{"orders": {Where: "id > 1"}}}
+			Ω(filtered.validate(pg14VersionNum)).NotTo(Succeed())
+			Ω(filtered.validate(pg15VersionNum)).To(Succeed())
+		})
+	})
+	Context("publish", func() {
+		It("should default to all operations in a fixed order", func() {
+			Ω(Publication{}.publish()).To(Equal(allPublishOperations))
+			Ω(Publication{Publish: []string{"delete", "insert"}}.publish()).To(Equal([]string{"insert", "delete"}))
+		})
+	})
+})
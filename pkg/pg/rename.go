@@ -0,0 +1,117 @@
+package pg
+
+import (
+	"fmt"
+)
+
+// existingPreviousName returns the first previous name that still exists (or an empty string if none exists)
+func existingPreviousName(previousNames []string, exists func(name string) (bool, error)) (string, error) {
+	for _, previousName := range previousNames {
+		found, err := exists(previousName)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			return previousName, nil
+		}
+	}
+	return "", nil
+}
+
+// renameFromPrevious renames the role when it does not exist yet, but one of its previous names does.
+// This keeps ownership, privileges and memberships of the role. It returns true when the role was renamed.
+func (r Role) renameFromPrevious(conn Conn) (renamed bool, err error) {
+	previousName, err := existingPreviousName(r.PreviousNames, func(name string) (bool, error) {
+		return NewRole(name).exists(conn)
+	})
+	if err != nil || previousName == "" {
+		return false, err
+	}
+	md5Password, err := hasMD5Password(conn, previousName)
+	if err != nil {
+		return false, err
+	}
+	err = conn.runQueryExec(fmt.Sprintf("ALTER ROLE %s RENAME TO %s", identifier(previousName), identifier(r.Name)))
+	if err != nil {
+		return false, err
+	}
+	if err = conn.renameManaged(ManagedObject{Kind: ObjectKindRole, Name: previousName}, r.Name); err != nil {
+		return false, err
+	}
+	log.Infof("Role '%s' successfully renamed to '%s'", previousName, r.Name)
+	if md5Password {
+		return true, r.setClearedPassword(conn)
+	}
+	return true, nil
+}
+
+// hasMD5Password returns true if the role has a md5 password (which PostgreSQL clears when the role is renamed, since
+// the role name is part of the hash)
+func hasMD5Password(conn Conn, roleName string) (bool, error) {
+	return conn.runQueryExists("SELECT rolname FROM pg_authid WHERE rolname = $1 AND rolpassword LIKE 'md5%'", roleName)
+}
+
+// setClearedPassword sets the password of the role again, after PostgreSQL cleared its md5 password on rename
+func (r Role) setClearedPassword(conn Conn) (err error) {
+	if !r.Password.IsSet() {
+		log.Warnf("PostgreSQL cleared the md5 password of role '%s' on rename, and the config has no password to set",
+			r.Name)
+		return nil
+	}
+	log.Infof("PostgreSQL cleared the md5 password of role '%s' on rename, setting it again", r.Name)
+	return r.reconcileSetPassword(conn)
+}
+
+// renameFromPrevious renames the database when it does not exist yet, but one of its previous names does.
+// It returns true when the database was renamed.
+func (d Database) renameFromPrevious(conn Conn) (renamed bool, err error) {
+	previousName, err := existingPreviousName(d.PreviousNames, func(name string) (bool, error) {
+		return Database{name: name}.exists(conn)
+	})
+	if err != nil || previousName == "" {
+		return false, err
+	}
+	renameQuery := fmt.Sprintf("ALTER DATABASE %s RENAME TO %s", identifier(previousName), identifier(d.name))
+	err = conn.runQueryExec(renameQuery)
+	if err != nil && d.Force.Enabled {
+		log.Warnf("Could not rename database '%s' (%v), terminating sessions", previousName, err)
+		previous := Database{name: previousName, Force: d.Force}
+		var allowed bool
+		if allowed, err = previous.allowsConnections(conn); err != nil {
+			return false, err
+		}
+		err = previous.forceRetry(conn, func() error { return conn.runQueryExec(renameQuery) })
+		if err == nil && allowed {
+			err = conn.runQueryExec(fmt.Sprintf("ALTER DATABASE %s ALLOW_CONNECTIONS true", identifier(d.name)))
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	if err = conn.renameManaged(ManagedObject{Kind: ObjectKindDatabase, Name: previousName}, d.name); err != nil {
+		return false, err
+	}
+	log.Infof("Database '%s' successfully renamed to '%s'", previousName, d.name)
+	return true, nil
+}
+
+// renameFromPrevious renames the schema when it does not exist yet, but one of its previous names does.
+// It returns true when the schema was renamed.
+func (s Schema) renameFromPrevious(conn *Conn) (renamed bool, err error) {
+	previousName, err := existingPreviousName(s.PreviousNames, func(name string) (bool, error) {
+		return Schema{name: name}.exists(conn)
+	})
+	if err != nil || previousName == "" {
+		return false, err
+	}
+	err = conn.runQueryExec(fmt.Sprintf("ALTER SCHEMA %s RENAME TO %s", identifier(previousName), identifier(s.name)))
+	if err != nil {
+		return false, err
+	}
+	previous := ManagedObject{Kind: ObjectKindSchema, Database: conn.DBName(), Name: previousName}
+	if err = conn.renameManaged(previous, s.name); err != nil {
+		return false, err
+	}
+	log.Infof("Schema '%s'.'%s' successfully renamed to '%s'", conn.DBName(), previousName, s.name)
+	return true, nil
+}
@@ -0,0 +1,37 @@
+package pg
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/Rename", func() {
+	existing := map[string]bool{"old2": true, "old3": true}
+	exists := func(name string) (bool, error) {
+		return existing[name], nil
+	}
+	Context("existingPreviousName", func() {
+		It("should return the first previous name that exists", func() {
+			Ω(existingPreviousName([]string{"old1", "old2", "old3"}, exists)).To(Equal("old2"))
+		})
+		It("should return an empty string when no previous name exists", func() {
+			Ω(existingPreviousName([]string{"old1"}, exists)).To(BeEmpty())
+			Ω(existingPreviousName(nil, exists)).To(BeEmpty())
+		})
+		It("should return errors", func() {
+			_, err := existingPreviousName([]string{"old1"}, func(string) (bool, error) {
+				return false, errors.New("failed")
+			})
+			Ω(err).To(HaveOccurred())
+		})
+	})
+	Context("Merge", func() {
+		It("should merge previous names without duplicates", func() {
+			merged := Role{Name: "new", PreviousNames: []string{"old1"}}.Merge(
+				Role{Name: "new", PreviousNames: []string{"old1", "old2"}})
+			Ω(merged.PreviousNames).To(Equal([]string{"old1", "old2"}))
+		})
+	})
+})
@@ -1,9 +1,46 @@
 package pg
 
-type replicationSlots map[string]replicationSlot
+import (
+	"fmt"
+	"strings"
+)
 
-// reconcile can be used to grant or revoke all Databases.
-func (rs replicationSlots) reconcile(primaryConn Conn) (err error) {
+const (
+	// pg14VersionNum is the first version where logical replication slots can be created with two_phase
+	pg14VersionNum = 140000
+	// pg17VersionNum is the first version where logical replication slots can be created with failover
+	pg17VersionNum = 170000
+)
+
+// SlotType is the type of a replication slot (physical or logical)
+type SlotType string
+
+const (
+	// SlotTypePhysical is used for streaming replication (default)
+	SlotTypePhysical SlotType = "physical"
+	// SlotTypeLogical is used for logical decoding with an output plugin (e.a. pgoutput)
+	SlotTypeLogical SlotType = "logical"
+)
+
+// ReplicationSlots is a map of all replication slots, where the key is the name of the slot
+type ReplicationSlots map[string]ReplicationSlot
+
+// UnmarshalYAML allows replication_slots to be set as a list of names (physical slots), or as a map of slots
+func (rs *ReplicationSlots) UnmarshalYAML(unmarshal func(any) error) error {
+	var names []string
+	if err := unmarshal(&names); err == nil {
+		*rs = ReplicationSlots{}
+		for _, name := range names {
+			(*rs)[name] = ReplicationSlot{}
+		}
+		return nil
+	}
+	type plain ReplicationSlots
+	return unmarshal((*plain)(rs))
+}
+
+// reconcile can be used to create all replication slots with state Present.
+func (rs ReplicationSlots) reconcile(primaryConn Conn) (err error) {
 	for slotName, slot := range rs {
 		slot.name = slotName
 		err := slot.create(primaryConn)
@@ -14,8 +51,8 @@ func (rs replicationSlots) reconcile(primaryConn Conn) (err error) {
 	return nil
 }
 
-// reconcile can be used to grant or revoke all Databases.
-func (rs replicationSlots) finalize(primaryConn Conn) (err error) {
+// finalize can be used to drop all replication slots with state Absent.
+func (rs ReplicationSlots) finalize(primaryConn Conn) (err error) {
 	for slotName, slot := range rs {
 		slot.name = slotName
 		err := slot.drop(primaryConn)
@@ -26,16 +63,149 @@ func (rs replicationSlots) finalize(primaryConn Conn) (err error) {
 	return nil
 }
 
-type replicationSlot struct {
+// ReplicationSlot holds the definition of a physical or logical replication slot
+type ReplicationSlot struct {
 	name  string
-	State State `yaml:"state"`
+	State State    `yaml:"state"`
+	Type  SlotType `yaml:"type"`
+	// Plugin is the output plugin of a logical slot (e.a. pgoutput)
+	Plugin string `yaml:"plugin"`
+	// Database is the database a logical slot is created in
+	Database string `yaml:"database"`
+	// ImmediatelyReserve reserves WAL for a physical slot when it is created (instead of when a client connects)
+	ImmediatelyReserve bool `yaml:"immediately_reserve"`
+	// TwoPhase enables decoding of prepared transactions for a logical slot (PostgreSQL 14 and newer)
+	TwoPhase bool `yaml:"two_phase"`
+	// Failover synchronizes a logical slot to standbys (PostgreSQL 17 and newer)
+	Failover bool `yaml:"failover"`
+	// Guardrails override the global slot guardrails for this slot
+	Guardrails SlotGuardrails `yaml:"guardrails"`
+}
+
+func (rs ReplicationSlot) slotType() SlotType {
+	if rs.Type == "" {
+		return SlotTypePhysical
+	}
+	return rs.Type
+}
+
+// validate checks the definition of the slot against the version of the server
+func (rs ReplicationSlot) validate(serverVersion int) error {
+	switch rs.slotType() {
+	case SlotTypePhysical:
+		if rs.Plugin != "" || rs.Database != "" || rs.TwoPhase || rs.Failover {
+			return fmt.Errorf("replication slot %s: plugin, database, two_phase and failover are only valid for "+
+				"logical slots", rs.name)
+		}
+	case SlotTypeLogical:
+		if rs.Plugin == "" || rs.Database == "" {
+			return fmt.Errorf("logical replication slot %s requires a plugin and a database", rs.name)
+		}
+		if rs.ImmediatelyReserve {
+			return fmt.Errorf("replication slot %s: immediately_reserve is only valid for physical slots", rs.name)
+		}
+		if rs.TwoPhase && serverVersion < pg14VersionNum {
+			return fmt.Errorf("replication slot %s: two_phase requires PostgreSQL 14 or newer", rs.name)
+		}
+		if rs.Failover && serverVersion < pg17VersionNum {
+			return fmt.Errorf("replication slot %s: failover requires PostgreSQL 17 or newer", rs.name)
+		}
+	default:
+		return fmt.Errorf("replication slot %s has invalid type %s (should be physical or logical)", rs.name, rs.Type)
+	}
+	return nil
+}
+
+// slotProperties are the properties of an existing slot, as read from pg_replication_slots
+type slotProperties struct {
+	Type     SlotType
+	Plugin   string
+	Database string
+	TwoPhase bool
+	Failover bool
+}
+
+// conflicts returns the differences with an existing slot that can only be resolved by recreating the slot
+func (rs ReplicationSlot) conflicts(current slotProperties) (conflicts []string) {
+	if current.Type != rs.slotType() {
+		conflicts = append(conflicts, fmt.Sprintf("type is %s instead of %s", current.Type, rs.slotType()))
+	}
+	if rs.slotType() != SlotTypeLogical {
+		return conflicts
+	}
+	if current.Plugin != rs.Plugin {
+		conflicts = append(conflicts, fmt.Sprintf("plugin is %s instead of %s", current.Plugin, rs.Plugin))
+	}
+	if current.Database != rs.Database {
+		conflicts = append(conflicts, fmt.Sprintf("database is %s instead of %s", current.Database, rs.Database))
+	}
+	return conflicts
+}
+
+// differences returns the differences with an existing slot that pgfga cannot change, but are harmless
+func (rs ReplicationSlot) differences(current slotProperties) (differences []string) {
+	if rs.slotType() != SlotTypeLogical || current.Type != SlotTypeLogical {
+		return nil
+	}
+	if current.TwoPhase != rs.TwoPhase {
+		differences = append(differences, fmt.Sprintf("two_phase is %t instead of %t", current.TwoPhase, rs.TwoPhase))
+	}
+	if current.Failover != rs.Failover {
+		differences = append(differences, fmt.Sprintf("failover is %t instead of %t", current.Failover, rs.Failover))
+	}
+	return differences
+}
+
+// properties returns the properties of the slot, and false if it does not exist
+func (rs ReplicationSlot) properties(conn Conn) (props slotProperties, exists bool, err error) {
+	serverVersion, err := conn.serverVersion()
+	if err != nil {
+		return props, false, err
+	}
+	twoPhase, failover := "false", "false"
+	if serverVersion >= pg14VersionNum {
+		twoPhase = "two_phase"
+	}
+	if serverVersion >= pg17VersionNum {
+		failover = "failover"
+	}
+	if err = conn.Connect(); err != nil {
+		return props, false, err
+	}
+	rows, err := conn.conn.Query(conn.ctx,
+		fmt.Sprintf(`SELECT slot_type, coalesce(plugin, ''), coalesce(database, ''), %s, %s
+			FROM pg_replication_slots WHERE slot_name = $1`, twoPhase, failover),
+		rs.name)
+	if err != nil {
+		return props, false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return props, false, rows.Err()
+	}
+	var slotType string
+	if err = rows.Scan(&slotType, &props.Plugin, &props.Database, &props.TwoPhase, &props.Failover); err != nil {
+		return props, false, err
+	}
+	props.Type = SlotType(slotType)
+	return props, true, nil
 }
 
-func (rs replicationSlot) exists(conn Conn) (exists bool, err error) {
+func (rs ReplicationSlot) exists(conn Conn) (exists bool, err error) {
 	return conn.runQueryExists("SELECT slot_name FROM pg_replication_slots WHERE slot_name = $1", rs.name)
 }
-func (rs replicationSlot) drop(conn Conn) (err error) {
-	if rs.State == Present {
+
+// slotConn returns a connection to the database of a logical slot (which is where logical slots are created and
+// dropped), or the primary connection for physical slots
+func slotConn(conn Conn, database string) Conn {
+	if database == "" {
+		return conn
+	}
+	return conn.SwitchDB(database)
+}
+
+func (rs ReplicationSlot) drop(conn Conn) (err error) {
+	if rs.State != Absent {
 		return nil
 	}
 	exists, err := rs.exists(conn)
@@ -43,29 +213,78 @@ func (rs replicationSlot) drop(conn Conn) (err error) {
 		return err
 	}
 	if exists {
-		err = conn.runQueryExec("SELECT pg_drop_replication_slot($1)", rs.name)
+		managed := ManagedObject{Kind: ObjectKindSlot, Name: rs.name}
+		if mayDrop, err := conn.mayDrop(managed); err != nil || !mayDrop {
+			return err
+		}
+		database, err := conn.runQueryGetOneField(
+			"SELECT coalesce(database, '') FROM pg_replication_slots WHERE slot_name = $1", rs.name)
+		if err != nil {
+			return err
+		}
+		dbConn := slotConn(conn, database)
+		if database != "" {
+			defer dbConn.Close()
+		}
+		err = dbConn.runQueryExec("SELECT pg_drop_replication_slot($1)", rs.name)
 		if err != nil {
 			return err
 		}
 		log.Infof("Replication slot '%s' successfully dropped", rs.name)
+		return conn.unregisterManaged(managed)
 	}
 	return nil
 }
 
-func (rs replicationSlot) create(conn Conn) (err error) {
+func (rs ReplicationSlot) create(conn Conn) (err error) {
 	if rs.State == Absent {
 		return nil
 	}
-	exists, err := rs.exists(conn)
+	serverVersion, err := conn.serverVersion()
 	if err != nil {
 		return err
 	}
-	if !exists {
-		err = conn.runQueryExec("SELECT pg_create_physical_replication_slot($1)", rs.name)
-		if err != nil {
-			return err
+	if err = rs.validate(serverVersion); err != nil {
+		return err
+	}
+	current, exists, err := rs.properties(conn)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if conflicts := rs.conflicts(current); len(conflicts) > 0 {
+			return fmt.Errorf("replication slot %s differs from its definition (%s), and should be recreated "+
+				"manually", rs.name, strings.Join(conflicts, ", "))
 		}
-		log.Infof("Created replication slot '%s'", rs.name)
+		for _, difference := range rs.differences(current) {
+			log.Warnf("Replication slot '%s' cannot be altered: %s", rs.name, difference)
+		}
+		return conn.adoptManaged(ManagedObject{Kind: ObjectKindSlot, Name: rs.name})
+	}
+	query, args := rs.createQuery(serverVersion)
+	dbConn := slotConn(conn, rs.Database)
+	if rs.Database != "" {
+		defer dbConn.Close()
+	}
+	if err = dbConn.runQueryExec(query, args...); err != nil {
+		return err
+	}
+	log.Infof("Created %s replication slot '%s'", rs.slotType(), rs.name)
+	return conn.registerManaged(ManagedObject{Kind: ObjectKindSlot, Name: rs.name})
+}
+
+// createQuery returns the query (and its arguments) to create the slot
+func (rs ReplicationSlot) createQuery(serverVersion int) (query string, args []any) {
+	if rs.slotType() == SlotTypePhysical {
+		return "SELECT pg_create_physical_replication_slot($1, $2)", []any{rs.name, rs.ImmediatelyReserve}
+	}
+	switch {
+	case serverVersion >= pg17VersionNum:
+		return "SELECT pg_create_logical_replication_slot($1, $2, false, $3, $4)",
+			[]any{rs.name, rs.Plugin, rs.TwoPhase, rs.Failover}
+	case serverVersion >= pg14VersionNum:
+		return "SELECT pg_create_logical_replication_slot($1, $2, false, $3)", []any{rs.name, rs.Plugin, rs.TwoPhase}
+	default:
+		return "SELECT pg_create_logical_replication_slot($1, $2)", []any{rs.name, rs.Plugin}
 	}
-	return nil
 }
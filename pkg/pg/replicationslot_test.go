@@ -3,16 +3,17 @@ package pg
 import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
 )
 
 func repSlotExists(conn Conn, name string) {
-	exists, err := replicationSlot{name: name}.exists(conn)
+	exists, err := ReplicationSlot{name: name}.exists(conn)
 	Ω(err).NotTo(HaveOccurred())
 	Ω(exists).To(BeTrue())
 }
 
 func repSlotNotExists(conn Conn, name string) {
-	exists, err := replicationSlot{name: name}.exists(conn)
+	exists, err := ReplicationSlot{name: name}.exists(conn)
 	Ω(err).NotTo(HaveOccurred())
 	Ω(exists).To(BeFalse())
 }
@@ -25,15 +26,15 @@ var _ = Describe("Pkg/Pg/Replicationslot", Ordered, func() {
 	var (
 		myConn Conn
 	)
-	repSlots := replicationSlots{
-		shouldExist:    replicationSlot{State: Present},
-		shouldNotExist: replicationSlot{State: Absent},
+	repSlots := ReplicationSlots{
+		shouldExist:    ReplicationSlot{State: Present},
+		shouldNotExist: ReplicationSlot{State: Absent},
 	}
 
 	BeforeAll(func() {
 		myConn = NewConn(ConnParams{})
 		for _, rs := range []string{shouldExist, shouldNotExist} {
-			Ω(replicationSlot{name: rs, State: Absent}.drop(myConn)).NotTo(
+			Ω(ReplicationSlot{name: rs, State: Absent}.drop(myConn)).NotTo(
 				HaveOccurred())
 		}
 	})
@@ -64,3 +65,55 @@ var _ = Describe("Pkg/Pg/Replicationslot", Ordered, func() {
 		})
 	})
 })
+
+var _ = Describe("Pkg/Pg/Replicationslot/Definition", func() {
+	logical := ReplicationSlot{name: "cdc", Type: SlotTypeLogical, Plugin: "pgoutput", Database: "appdb"}
+	Context("UnmarshalYAML", func() {
+		It("should accept a list of physical slots and a map of slots", func() {
+			var slots ReplicationSlots
+			Ω(yaml.Unmarshal([]byte("[standby1]"), &slots)).To(Succeed())
+			Ω(slots).To(Equal(ReplicationSlots{"standby1": ReplicationSlot{}}))
+			Ω(yaml.Unmarshal([]byte("cdc: {type: logical, plugin: pgoutput, database: appdb}"), &slots)).To(Succeed())
+			Ω(slots["cdc"]).To(Equal(ReplicationSlot{Type: SlotTypeLogical, Plugin: "pgoutput", Database: "appdb"}))
+		})
+	})
+	Context("validate", func() {
+		It("should check options against the type and server version", func() {
+			Ω(ReplicationSlot{ImmediatelyReserve: true}.validate(pg14VersionNum)).To(Succeed())
+			Ω(ReplicationSlot{Plugin: "pgoutput"}.validate(pg14VersionNum)).NotTo(Succeed())
+			Ω(ReplicationSlot{Type: SlotTypeLogical}.validate(pg14VersionNum)).NotTo(Succeed())
+			Ω(ReplicationSlot{Type: "unknown"}.validate(pg14VersionNum)).NotTo(Succeed())
+			Ω(logical.validate(pg14VersionNum)).To(Succeed())
+			twoPhase := logical
+			twoPhase.TwoPhase = true
+			Ω(twoPhase.validate(pg14VersionNum - 1)).NotTo(Succeed())
+			Ω(twoPhase.validate(pg14VersionNum)).To(Succeed())
+			failover := logical
+			failover.Failover = true
+			Ω(failover.validate(pg16VersionNum)).NotTo(Succeed())
+			Ω(failover.validate(pg17VersionNum)).To(Succeed())
+		})
+	})
+	Context("conflicts and differences", func() {
+		It("should detect slots with another type or plugin", func() {
+			Ω(ReplicationSlot{}.conflicts(slotProperties{Type: SlotTypePhysical})).To(BeEmpty())
+			Ω(ReplicationSlot{}.conflicts(slotProperties{Type: SlotTypeLogical})).To(
+				Equal([]string{"type is logical instead of physical"}))
+			current := slotProperties{Type: SlotTypeLogical, Plugin: "wal2json", Database: "appdb", TwoPhase: true}
+			Ω(logical.conflicts(current)).To(Equal([]string{"plugin is wal2json instead of pgoutput"}))
+			Ω(logical.differences(current)).To(Equal([]string{"two_phase is true instead of false"}))
+		})
+	})
+	Context("createQuery", func() {
+		It("should only use arguments that the server supports", func() {
+			query, args := ReplicationSlot{name: "standby1", ImmediatelyReserve: true}.createQuery(pg17VersionNum)
+			Ω(query).To(Equal("SELECT pg_create_physical_replication_slot($1, $2)"))
+			Ω(args).To(Equal([]any{"standby1", true}))
+			query, args = logical.createQuery(pg14VersionNum - 1)
+			Ω(query).To(Equal("SELECT pg_create_logical_replication_slot($1, $2)"))
+			Ω(args).To(Equal([]any{"cdc", "pgoutput"}))
+			_, args = logical.createQuery(pg17VersionNum)
+			Ω(args).To(Equal([]any{"cdc", "pgoutput", false, false}))
+		})
+	})
+})
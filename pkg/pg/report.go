@@ -0,0 +1,45 @@
+package pg
+
+import (
+	"time"
+)
+
+// ExpiringRole holds the name and expiry of a role that can login
+type ExpiringRole struct {
+	Name       string
+	ValidUntil time.Time
+}
+
+// Expired returns true if the role is already expired at the specified moment
+func (er ExpiringRole) Expired(at time.Time) bool {
+	return er.ValidUntil.Before(at)
+}
+
+// ExpiringRoles returns all roles that can login and expire within the specified period (including expired roles)
+func (h *Handler) ExpiringRoles(within time.Duration) (expiring []ExpiringRole, err error) {
+	conn := h.getPrimaryConnection()
+	if err = conn.Connect(); err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.conn.Query(conn.ctx,
+		`SELECT rolname, rolvaliduntil FROM pg_roles
+		WHERE rolcanlogin
+		AND rolvaliduntil IS NOT NULL
+		AND rolvaliduntil != 'infinity'
+		AND rolvaliduntil < $1
+		ORDER BY rolvaliduntil, rolname`,
+		time.Now().Add(within))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var role ExpiringRole
+		if err = rows.Scan(&role.Name, &role.ValidUntil); err != nil {
+			return nil, err
+		}
+		expiring = append(expiring, role)
+	}
+	return expiring, rows.Err()
+}
@@ -1,21 +1,13 @@
 package pg
 
 import (
-	"context"
 	"time"
 
-	// md5 is weak, but it is still an accepted password algorithm in Postgres.
-	// #nosec
-	"crypto/md5"
 	"fmt"
-	"strings"
+	"maps"
+	"slices"
 
-	"github.com/jackc/pgx/v4"
-)
-
-const (
-	md5PasswordLength = 35
-	md5PasswordPrefix = "md5"
+	"github.com/pgvillage-tools/pgfga/pkg/credential"
 )
 
 // Roles is a map of all roles that should be created
@@ -45,6 +37,9 @@ func (rs Roles) reconcile(primaryConn Conn) (err error) {
 
 // reconcile can be used to grant or revoke all Databases.
 func (rs Roles) finalize(primaryConn Conn) (err error) {
+	if err = rs.checkReassignTargets(); err != nil {
+		return err
+	}
 	for roleName, role := range rs {
 		role.Name = roleName
 		err := role.drop(primaryConn)
@@ -57,29 +52,118 @@ func (rs Roles) finalize(primaryConn Conn) (err error) {
 
 // Role is a struct to hold all important info about one PostgreSQL role
 type Role struct {
-	Name     string
-	Options  RoleOptionMap
-	State    State
-	Password string
-	Expiry   time.Time
+	Name    string
+	Options RoleOptionMap
+	State   State
+	// Password is resolved when the password is reconciled, so that it is not part of the parsed config
+	Password credential.Credential
+	// PasswordEncryption is the algorithm used to hash a cleartext Password (defaults to md5)
+	PasswordEncryption PasswordEncryption
+	Expiry             time.Time
+	// ExpiryAfter sets the expiry relative to the moment PgFga sets a new password (when Expiry is not set)
+	ExpiryAfter time.Duration
+	// ConnectionLimit is the maximum number of concurrent connections for this role (-1 means no limit, nil means
+	// unmanaged)
+	ConnectionLimit *int
+	Settings        Settings
+	// PreviousNames are renamed to Name when the role does not exist, but a role with a previous name does
+	PreviousNames []string
+	// ReassignTo is the role that owned objects are reassigned to when this role is dropped. When not set, objects are
+	// reassigned to the owner of the database they are in.
+	ReassignTo string
+	// Force terminates sessions of this role when dropping it fails
+	Force ForceOptions
+	// SoftDelete renames this role instead of dropping it, and drops it after a retention period
+	SoftDelete SoftDelete
+	// AuthoritativeOptions means that all options that are not defined are reset to their PostgreSQL defaults
+	AuthoritativeOptions bool
+	// AuthoritativeMemberships means that all memberships of this role that are not declared are revoked
+	AuthoritativeMemberships bool
+	// Adopt registers this role as managed when it already exists (with strict.adopt_existing). It is only set for
+	// users and roles that are defined in the config, and not for roles that are only referenced (e.a. as memberof
+	// target or database owner).
+	Adopt bool
 }
 
 // Clone will return a clone of this role
 func (r Role) Clone() Role {
-	return Role{
-		Name:    r.Name,
-		Options: r.Options.Clone(),
-		State:   r.State,
+	clone := Role{
+		Name:                     r.Name,
+		Options:                  r.Options.Clone(),
+		State:                    r.State,
+		Password:                 r.Password,
+		PasswordEncryption:       r.PasswordEncryption,
+		Expiry:                   r.Expiry,
+		ExpiryAfter:              r.ExpiryAfter,
+		Settings:                 maps.Clone(r.Settings),
+		PreviousNames:            slices.Clone(r.PreviousNames),
+		ReassignTo:               r.ReassignTo,
+		Force:                    r.Force,
+		SoftDelete:               r.SoftDelete,
+		AuthoritativeOptions:     r.AuthoritativeOptions,
+		AuthoritativeMemberships: r.AuthoritativeMemberships,
+		Adopt:                    r.Adopt,
+	}
+	if r.ConnectionLimit != nil {
+		connectionLimit := *r.ConnectionLimit
+		clone.ConnectionLimit = &connectionLimit
 	}
+	return clone
 }
 
 // Merge will merge 2 Roles into a new merged Role
 func (r Role) Merge(other Role) Role {
 	mergedRole := r.Clone()
 	mergedRole.Options = r.Options.Merge(other.Options)
-	if other.State == Present {
-		mergedRole.State = Present
+	if other.Password.IsSet() {
+		mergedRole.Password = other.Password
+	}
+	if other.PasswordEncryption != "" {
+		mergedRole.PasswordEncryption = other.PasswordEncryption
+	}
+	if !other.Expiry.IsZero() {
+		mergedRole.Expiry = other.Expiry
+	}
+	if other.ExpiryAfter != 0 {
+		mergedRole.ExpiryAfter = other.ExpiryAfter
+	}
+	if other.ConnectionLimit != nil {
+		connectionLimit := *other.ConnectionLimit
+		mergedRole.ConnectionLimit = &connectionLimit
+	}
+	if other.Settings != nil {
+		if mergedRole.Settings == nil {
+			mergedRole.Settings = Settings{}
+		}
+		maps.Copy(mergedRole.Settings, other.Settings)
+	}
+	for _, previousName := range other.PreviousNames {
+		if !slices.Contains(mergedRole.PreviousNames, previousName) {
+			mergedRole.PreviousNames = append(mergedRole.PreviousNames, previousName)
+		}
+	}
+	if other.ReassignTo != "" {
+		mergedRole.ReassignTo = other.ReassignTo
+	}
+	if other.Force.Enabled {
+		mergedRole.Force = other.Force
+	}
+	if other.SoftDelete.Enabled {
+		mergedRole.SoftDelete = other.SoftDelete
+	}
+	// Once any definition of this role asks for authoritative options or memberships, it is applied
+	if other.AuthoritativeOptions {
+		mergedRole.AuthoritativeOptions = true
+	}
+	if other.AuthoritativeMemberships {
+		mergedRole.AuthoritativeMemberships = true
+	}
+	if other.Adopt {
+		mergedRole.Adopt = true
 	}
+	// The state of the latest definition is used, so that a role that was only referenced before (e.a. as memberof
+	// target, which creates it as Present) can still be defined as Absent
+	mergedRole.State = other.State
 	return mergedRole
 }
 
@@ -105,10 +189,12 @@ func (r Role) reconcile(conn Conn) (err error) {
 	for _, recFunc := range []func(Conn) error{
 		r.create,
 		r.reconcileRoleOptions,
+		r.reconcileConnectionLimit,
 		r.reconcileSetExpiry,
 		r.reconcileResetExpiry,
 		r.reconcileSetPassword,
 		r.reconcileResetPassword,
+		r.reconcileSettings,
 	} {
 		err := recFunc(conn)
 		if err != nil {
@@ -118,44 +204,6 @@ func (r Role) reconcile(conn Conn) (err error) {
 	return nil
 }
 
-func (r *Role) drop(c Conn) (err error) {
-	if r.State == Present {
-		return nil
-	}
-	existsQuery := "SELECT rolname FROM pg_Roles WHERE rolname = $1 AND rolname != CURRENT_USER"
-	if exists, err := c.runQueryExists(existsQuery, r.Name); err != nil {
-		return err
-	} else if !exists {
-		return nil
-	}
-	var dbname string
-	var newOwner string
-	query := `select db.datname, o.rolname as newOwner from pg_database db inner join 
-			  pg_Roles o on db.datdba = o.oid where db.datname != 'template0'`
-	row := c.conn.QueryRow(context.Background(), query)
-	for {
-		scanErr := row.Scan(&dbname, &newOwner)
-		if scanErr == pgx.ErrNoRows {
-			break
-		} else if scanErr != nil {
-			return fmt.Errorf("error getting ReadOnly grants (qry: %s, err %s)", query, err)
-		}
-		dbConn := c.SwitchDB(dbname)
-		err = dbConn.runQueryExec(fmt.Sprintf("REASSIGN OWNED BY %s TO %s", identifier(r.Name), identifier(newOwner)))
-		if err != nil {
-			return err
-		}
-		log.Debugf("Reassigned ownership from '%s' to '%s' in db '%s'", r.Name, newOwner, dbname)
-	}
-	err = c.runQueryExec(fmt.Sprintf("DROP ROLE %s", identifier(r.Name)))
-	if err != nil {
-		return err
-	}
-	r.State = Absent
-	log.Infof("Role '%s' successfully dropped", r.Name)
-	return nil
-}
-
 func (r Role) create(conn Conn) (err error) {
 	if r.State == Absent {
 		return nil
@@ -165,13 +213,23 @@ func (r Role) create(conn Conn) (err error) {
 		return err
 	}
 	if !exists {
+		if renamed, err := r.renameFromPrevious(conn); err != nil || renamed {
+			return err
+		}
+		if restored, err := r.restoreSoftDeleted(conn); err != nil || restored {
+			return err
+		}
 		err = conn.runQueryExec(fmt.Sprintf("CREATE ROLE %s", identifier(r.Name)))
 		if err != nil {
 			return err
 		}
 		log.Infof("Role '%s' successfully created", r.Name)
+		return conn.registerManaged(ManagedObject{Kind: ObjectKindRole, Name: r.Name})
 	}
-	return nil
+	if !r.Adopt {
+		return nil
+	}
+	return conn.adoptManaged(ManagedObject{Kind: ObjectKindRole, Name: r.Name})
 }
 
 func (r Role) hasOptions(conn Conn, option RoleOption) (has bool, err error) {
@@ -180,8 +238,31 @@ func (r Role) hasOptions(conn Conn, option RoleOption) (has bool, err error) {
 		r.Name)
 }
 
+// roleOptions returns the options that should be checked. With authoritative options, all options that are not defined
+// get their default. This is skipped for the user PgFga is connected as, so that PgFga cannot lock itself out.
+func (r Role) roleOptions(conn Conn) (options RoleOptionList, err error) {
+	if !r.AuthoritativeOptions {
+		return r.Options.effective(), nil
+	}
+	isCurrentUser, err := conn.runQueryExists(
+		"SELECT rolname FROM pg_roles WHERE rolname = $1 AND rolname = CURRENT_USER",
+		r.Name)
+	if err != nil {
+		return nil, err
+	}
+	if isCurrentUser {
+		log.Warnf("Role '%s' is the current user, only defined options are checked", r.Name)
+		return r.Options.effective(), nil
+	}
+	return r.Options.withDefaults().effective(), nil
+}
+
 func (r Role) reconcileRoleOptions(conn Conn) (err error) {
-	for option := range r.Options {
+	options, err := r.roleOptions(conn)
+	if err != nil {
+		return err
+	}
+	for _, option := range options {
 		hasOption, err := r.hasOptions(conn, option)
 		if err != nil {
 			return err
@@ -203,47 +284,45 @@ func (r Role) reconcileRoleOptions(conn Conn) (err error) {
 
 // SetPassword can be used to set a password for a user.
 func (r *Role) SetPassword(password string) {
-	r.Password = password
+	r.Password = credential.Credential{Value: password}
 }
 
 func (r Role) reconcileSetPassword(conn Conn) (err error) {
-	if r.Password == "" || !r.Options.IsEnabled(RoleLogin) {
+	if !r.Password.IsSet() || !r.Options.IsEnabled(RoleLogin) {
 		return nil
 	}
-	var hashedPassword string
-	if len(r.Password) == md5PasswordLength && strings.HasPrefix(r.Password, md5PasswordPrefix) {
-		hashedPassword = r.Password
-	} else {
-		// #nosec
-		hashedPassword = fmt.Sprintf("%s%x", md5PasswordPrefix, md5.Sum([]byte(r.Password+r.Name)))
+	password, err := r.Password.GetCred()
+	if err != nil {
+		return fmt.Errorf("could not retrieve password for user %s: %w", r.Name, err)
 	}
-	checkQry := `
-	SELECT rolname 
-	FROM pg_Roles 
-	WHERE rolname = $1
-		AND rolname NOT IN (
-			SELECT usename 
-			FROM pg_shadow 
-			WHERE usename = $1
-			AND COALESCE(passwd, '') = $2);`
-	exists, err := conn.runQueryExists(checkQry, r.Name, hashedPassword)
+	currentPassword, err := conn.runQueryGetOneField(
+		"SELECT COALESCE(rolpassword, '') FROM pg_authid WHERE rolname = $1",
+		r.Name)
 	if err != nil {
 		return err
 	}
-	if exists {
-		err = conn.runQueryExec(fmt.Sprintf("ALTER ROLE %s WITH ENCRYPTED PASSWORD %s", identifier(r.Name),
-			quotedSQLValue(hashedPassword)))
-		if err != nil {
-			return err
-		}
-		log.Infof("successfully set new password for user '%s'", r.Name)
+	if passwordMatches(r.Name, password, r.PasswordEncryption, currentPassword) {
+		return nil
+	}
+	hashedPassword, err := hashPassword(r.Name, password, r.PasswordEncryption)
+	if err != nil {
+		return err
+	}
+	err = conn.runQueryExec(fmt.Sprintf("ALTER ROLE %s WITH ENCRYPTED PASSWORD %s", identifier(r.Name),
+		quotedSQLValue(hashedPassword)))
+	if err != nil {
+		return err
+	}
+	log.Infof("successfully set new password for user '%s'", r.Name)
+	if r.Expiry.IsZero() && r.ExpiryAfter > 0 {
+		return r.setExpiry(conn, time.Now().Add(r.ExpiryAfter))
 	}
 	return nil
 }
 
 // resetPassword can be used to reset the password of a PostgreSQL user
 func (r Role) reconcileResetPassword(conn Conn) (err error) {
-	if r.Password != "" && r.Options.IsEnabled(RoleLogin) {
+	if r.Password.IsSet() && r.Options.IsEnabled(RoleLogin) {
 		return nil
 	}
 	checkQry := `SELECT usename FROM pg_shadow WHERE usename = $1
@@ -267,8 +346,38 @@ func (r *Role) SetExpiry(expiry time.Time) {
 	r.Expiry = expiry
 }
 
+// setExpiry sets the expiry of a role to a specific moment
+func (r Role) setExpiry(conn Conn, expiry time.Time) (err error) {
+	err = conn.runQueryExec(fmt.Sprintf("ALTER ROLE %s VALID UNTIL %s", identifier(r.Name),
+		quotedSQLValue(expiry.Format(time.RFC3339))))
+	if err != nil {
+		return err
+	}
+	log.Infof("successfully set new expiry for user '%s' to '%s'", r.Name, expiry.Format(time.RFC3339))
+	return nil
+}
+
+// reconcileSetRelativeExpiry sets an expiry relative to now for roles that have no expiry yet.
+// The expiry is renewed when PgFga sets a new password (see reconcileSetPassword).
+func (r Role) reconcileSetRelativeExpiry(conn Conn) (err error) {
+	checkQry := `SELECT rolname FROM pg_Roles
+	WHERE rolname = $1
+	AND (rolvaliduntil IS NULL OR rolvaliduntil = 'infinity');`
+	exists, err := conn.runQueryExists(checkQry, r.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return r.setExpiry(conn, time.Now().Add(r.ExpiryAfter))
+	}
+	return nil
+}
+
 func (r Role) reconcileSetExpiry(conn Conn) (err error) {
 	if r.Expiry.IsZero() {
+		if r.ExpiryAfter > 0 {
+			return r.reconcileSetRelativeExpiry(conn)
+		}
 		return nil
 	}
 	formattedExpiry := r.Expiry.Format(time.RFC3339)
@@ -279,19 +388,14 @@ func (r Role) reconcileSetExpiry(conn Conn) (err error) {
 		return err
 	}
 	if exists {
-		err = conn.runQueryExec(fmt.Sprintf("ALTER ROLE %s VALID UNTIL %s", identifier(r.Name),
-			quotedSQLValue(formattedExpiry)))
-		if err != nil {
-			return err
-		}
-		log.Infof("successfully set new expiry for user '%s'", r.Name)
+		return r.setExpiry(conn, r.Expiry)
 	}
 	return nil
 }
 
 // ResetExpiry can be used to reset the expiry of a PostgreSQL User
 func (r Role) reconcileResetExpiry(conn Conn) (err error) {
-	if !r.Expiry.IsZero() {
+	if !r.Expiry.IsZero() || r.ExpiryAfter > 0 {
 		return nil
 	}
 	checkQry := `SELECT rolname
@@ -312,3 +416,30 @@ func (r Role) reconcileResetExpiry(conn Conn) (err error) {
 	}
 	return nil
 }
+
+// reconcileSettings can be used to set and reset the settings of a role (ALTER ROLE ... SET)
+func (r Role) reconcileSettings(conn Conn) (err error) {
+	return settingsTarget{role: r.Name}.reconcile(conn, r.Settings)
+}
+
+// reconcileConnectionLimit can be used to set the connection limit of a role. Without a connection limit in the
+// config (e.a. for roles that pgfga creates implicitly), the connection limit is left as it is.
+func (r Role) reconcileConnectionLimit(conn Conn) (err error) {
+	if r.ConnectionLimit == nil {
+		return nil
+	}
+	connectionLimit := *r.ConnectionLimit
+	checkQry := `SELECT rolname FROM pg_roles WHERE rolname = $1 AND rolconnlimit != $2`
+	exists, err := conn.runQueryExists(checkQry, r.Name, connectionLimit)
+	if err != nil {
+		return err
+	}
+	if exists {
+		err = conn.runQueryExec(fmt.Sprintf("ALTER ROLE %s CONNECTION LIMIT %d", identifier(r.Name), connectionLimit))
+		if err != nil {
+			return err
+		}
+		log.Infof("successfully set connection limit for role '%s' to %d", r.Name, connectionLimit)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package pg
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// roleDatabaseOwner is used as reassign target to reassign objects to the owner of every database
+const roleDatabaseOwner = ""
+
+func (r *Role) drop(c Conn) (err error) {
+	if r.State != Absent {
+		return nil
+	}
+	if r.SoftDelete.Enabled {
+		return r.softDelete(c)
+	}
+	existsQuery := "SELECT rolname FROM pg_Roles WHERE rolname = $1 AND rolname != CURRENT_USER"
+	if exists, err := c.runQueryExists(existsQuery, r.Name); err != nil {
+		return err
+	} else if !exists {
+		return nil
+	}
+	managed := ManagedObject{Kind: ObjectKindRole, Name: r.Name}
+	if mayDrop, err := c.mayDrop(managed); err != nil || !mayDrop {
+		return err
+	}
+	err = r.reassignAndDrop(c)
+	if err != nil && r.Force.Enabled {
+		log.Warnf("Could not drop role '%s' (%v), terminating sessions", r.Name, err)
+		err = r.forceRetry(c, func() error { return r.reassignAndDrop(c) })
+	}
+	if err != nil {
+		return err
+	}
+	if err = c.unregisterManaged(managed); err != nil {
+		return err
+	}
+	r.State = Absent
+	log.Infof("Role '%s' successfully dropped", r.Name)
+	return nil
+}
+
+// reassignAndDrop reassigns all owned objects, revokes all memberships and drops the role
+func (r Role) reassignAndDrop(c Conn) (err error) {
+	for _, dropFunc := range []func(Conn) error{
+		r.reassignOwnedDatabases,
+		r.reassignAndDropOwned,
+		r.revokeMemberships,
+	} {
+		if err = dropFunc(c); err != nil {
+			return err
+		}
+	}
+	return c.runQueryExec(fmt.Sprintf("DROP ROLE %s", identifier(r.Name)))
+}
+
+// terminateSessions blocks new logins for the role, and terminates all sessions after the grace period
+func (r Role) terminateSessions(c Conn) (err error) {
+	if err = c.runQueryExec(fmt.Sprintf("ALTER ROLE %s NOLOGIN", identifier(r.Name))); err != nil {
+		return err
+	}
+	log.Infof("Blocked new logins for role '%s'", r.Name)
+	return sessionsOfRole.terminate(c, r.Name, r.Force.gracePeriod())
+}
+
+// forceRetry terminates all sessions of the role and runs retry again. When that fails, the role gets LOGIN again if
+// it could login before.
+func (r Role) forceRetry(c Conn, retry func() error) (err error) {
+	canLogin, err := c.runQueryExists("SELECT rolname FROM pg_roles WHERE rolname = $1 AND rolcanlogin", r.Name)
+	if err != nil {
+		return err
+	}
+	if err = r.terminateSessions(c); err == nil {
+		if err = retry(); err == nil {
+			return nil
+		}
+	}
+	if canLogin {
+		if restoreErr := c.runQueryExec(fmt.Sprintf("ALTER ROLE %s LOGIN", identifier(r.Name))); restoreErr != nil {
+			log.Errorf("Could not allow logins for role '%s' again: %v", r.Name, restoreErr)
+		}
+	}
+	return err
+}
+
+// checkReassignTargets returns an error when a role with state Absent reassigns its objects to itself, or to another
+// role that is dropped as well. DROP OWNED would then drop the objects instead of leaving them with the target.
+func (rs Roles) checkReassignTargets() error {
+	for _, roleName := range slices.Sorted(maps.Keys(rs)) {
+		role := rs[roleName]
+		if role.State != Absent || role.ReassignTo == roleDatabaseOwner {
+			continue
+		}
+		if role.ReassignTo == roleName {
+			return fmt.Errorf("cannot drop role %s: reassign_to should not be the role itself", roleName)
+		}
+		if target, exists := rs[role.ReassignTo]; exists && target.State == Absent {
+			return fmt.Errorf("cannot drop role %s: role %s to reassign objects to is dropped as well", roleName,
+				role.ReassignTo)
+		}
+	}
+	return nil
+}
+
+// reassignTarget returns the role that objects in a database are reassigned to when this role is dropped
+func (r Role) reassignTarget(dbOwner string) string {
+	if r.ReassignTo == roleDatabaseOwner {
+		return dbOwner
+	}
+	return r.ReassignTo
+}
+
+// checkReassignTarget returns an error when the role that objects should be reassigned to does not exist
+func (r Role) checkReassignTarget(c Conn) (err error) {
+	if r.ReassignTo == roleDatabaseOwner {
+		return nil
+	}
+	exists, err := NewRole(r.ReassignTo).exists(c)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("cannot drop role %s: role %s to reassign objects to does not exist", r.Name, r.ReassignTo)
+	}
+	return nil
+}
+
+// reassignOwnedDatabases changes the owner of all databases that are owned by this role, to the reassign target (or
+// the current user when objects are reassigned to the database owner).
+func (r Role) reassignOwnedDatabases(c Conn) (err error) {
+	if err = r.checkReassignTarget(c); err != nil {
+		return err
+	}
+	databases, err := c.runQueryGetOneColumn(
+		`SELECT datname FROM pg_database db
+		INNER JOIN pg_roles rol ON db.datdba = rol.oid
+		WHERE rolname = $1
+		ORDER BY datname`,
+		r.Name)
+	if err != nil {
+		return err
+	}
+	for _, dbName := range databases {
+		newOwner := r.ReassignTo
+		if newOwner == roleDatabaseOwner {
+			if newOwner, err = c.runQueryGetOneField("SELECT CURRENT_USER"); err != nil {
+				return err
+			}
+		}
+		err = c.runQueryExec(fmt.Sprintf("ALTER DATABASE %s OWNER TO %s", identifier(dbName), identifier(newOwner)))
+		if err != nil {
+			return err
+		}
+		log.Infof("Database '%s' moved from '%s' to '%s'", dbName, r.Name, newOwner)
+	}
+	return nil
+}
+
+// ownedObjects returns a description of all objects in the current database that are owned by this role
+func (r Role) ownedObjects(dbConn Conn) (objects []string, err error) {
+	return dbConn.runQueryGetOneColumn(
+		`SELECT pg_describe_object(classid, objid, objsubid) FROM pg_shdepend
+		WHERE refclassid = 'pg_authid'::regclass
+		AND refobjid = (SELECT oid FROM pg_roles WHERE rolname = $1)
+		AND deptype = 'o'
+		AND dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+		ORDER BY 1`,
+		r.Name)
+}
+
+// reassignAndDropOwned reassigns all objects owned by this role in all connectable databases, and drops all
+// privileges and default privileges of this role (DROP OWNED)
+func (r Role) reassignAndDropOwned(c Conn) (err error) {
+	query := `SELECT datname, rolname FROM pg_database db
+		INNER JOIN pg_roles rol ON db.datdba = rol.oid
+		WHERE datallowconn
+		ORDER BY datname`
+	if err = c.Connect(); err != nil {
+		return err
+	}
+	rows, err := c.conn.Query(c.ctx, query)
+	if err != nil {
+		return fmt.Errorf("error getting databases (qry: %s, err %w)", query, err)
+	}
+	dbOwners := map[string]string{}
+	var dbNames []string
+	for rows.Next() {
+		var dbName, dbOwner string
+		if err = rows.Scan(&dbName, &dbOwner); err != nil {
+			rows.Close()
+			return err
+		}
+		dbNames = append(dbNames, dbName)
+		dbOwners[dbName] = dbOwner
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for _, dbName := range dbNames {
+		if err = r.reassignAndDropOwnedInDB(c.SwitchDB(dbName), r.reassignTarget(dbOwners[dbName])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r Role) reassignAndDropOwnedInDB(dbConn Conn, newOwner string) (err error) {
+	defer dbConn.Close()
+	dbName := dbConn.DBName()
+	objects, err := r.ownedObjects(dbConn)
+	if err != nil {
+		return err
+	}
+	if newOwner == r.Name {
+		// DROP OWNED would drop all objects of the role, instead of reassigning them
+		return fmt.Errorf("cannot drop role %s: objects in db %s would be reassigned to the role itself", r.Name, dbName)
+	}
+	err = dbConn.runQueryExec(fmt.Sprintf("REASSIGN OWNED BY %s TO %s", identifier(r.Name), identifier(newOwner)))
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		log.Infof("Reassigned %s from '%s' to '%s' in db '%s'", object, r.Name, newOwner, dbName)
+	}
+	err = dbConn.runQueryExec(fmt.Sprintf("DROP OWNED BY %s", identifier(r.Name)))
+	if err != nil {
+		return err
+	}
+	log.Debugf("Dropped privileges of '%s' in db '%s'", r.Name, dbName)
+	return nil
+}
+
+// revokeMemberships revokes all roles granted to this role, and this role from all its members
+func (r Role) revokeMemberships(c Conn) (err error) {
+	granteds, err := c.runQueryGetOneColumn(
+		`SELECT DISTINCT granted.rolname FROM pg_auth_members auth
+		INNER JOIN pg_roles granted ON auth.roleid = granted.oid
+		INNER JOIN pg_roles grantee ON auth.member = grantee.oid
+		WHERE grantee.rolname = $1`,
+		r.Name)
+	if err != nil {
+		return err
+	}
+	members, err := c.runQueryGetOneColumn(
+		`SELECT DISTINCT grantee.rolname FROM pg_auth_members auth
+		INNER JOIN pg_roles granted ON auth.roleid = granted.oid
+		INNER JOIN pg_roles grantee ON auth.member = grantee.oid
+		WHERE granted.rolname = $1`,
+		r.Name)
+	if err != nil {
+		return err
+	}
+	var grants Grants
+	for _, granted := range granteds {
+		grants = append(grants, Grant{Grantee: Role{Name: r.Name}, Granted: Role{Name: granted}, State: Absent})
+	}
+	for _, member := range members {
+		grants = append(grants, Grant{Grantee: Role{Name: member}, Granted: Role{Name: r.Name}, State: Absent})
+	}
+	return grants.finalize(c)
+}
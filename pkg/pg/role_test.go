@@ -1,6 +1,10 @@
 package pg
 
 import (
+	"time"
+
+	"github.com/pgvillage-tools/pgfga/pkg/credential"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -61,3 +65,60 @@ var _ = Describe("Pkg/Pg/Role", Ordered, func() {
 		})
 	})
 })
+
+var _ = Describe("Pkg/Pg/Role/Attributes", func() {
+	Context("Merge", func() {
+		It("should keep password, expiry and connection limit", func() {
+			connectionLimit := 5
+			expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+			merged := NewRole("merged").Merge(Role{
+				Password:        credential.Credential{Value: "secret"},
+				Expiry:          expiry,
+				ConnectionLimit: &connectionLimit,
+			})
+			Ω(merged.Password.GetCred()).To(Equal("secret"))
+			Ω(merged.Expiry).To(Equal(expiry))
+			Ω(*merged.ConnectionLimit).To(Equal(connectionLimit))
+			connectionLimit = 10
+			Ω(*merged.ConnectionLimit).To(Equal(5))
+		})
+		It("should use the state of the latest definition", func() {
+			Ω(NewRole("merged").Merge(Role{State: Absent}).State).To(Equal(Absent))
+			Ω(Role{State: Absent}.Merge(NewRole("merged")).State).To(Equal(Present))
+		})
+		It("should adopt roles once any definition is adopted", func() {
+			Ω(NewRole("merged").Merge(Role{Adopt: true}).Merge(NewRole("merged")).Adopt).To(BeTrue())
+		})
+		It("should keep authoritative memberships once set", func() {
+			merged := NewRole("merged").Merge(Role{AuthoritativeMemberships: true})
+			Ω(merged.AuthoritativeMemberships).To(BeTrue())
+			Ω(merged.Merge(NewRole("merged")).AuthoritativeMemberships).To(BeTrue())
+		})
+	})
+	Context("ConnectionLimit", func() {
+		It("should be unmanaged by default", func() {
+			Ω(NewRole("unmanaged").ConnectionLimit).To(BeNil())
+		})
+	})
+	Context("reassignTarget", func() {
+		It("should default to the database owner", func() {
+			Ω(NewRole("dropped").reassignTarget("dbowner")).To(Equal("dbowner"))
+			Ω(Role{Name: "dropped", ReassignTo: "dba"}.reassignTarget("dbowner")).To(Equal("dba"))
+		})
+		It("should be merged", func() {
+			Ω(NewRole("dropped").Merge(Role{ReassignTo: "dba"}).ReassignTo).To(Equal("dba"))
+		})
+		It("should not be the dropped role itself, or another dropped role", func() {
+			Ω(Roles{
+				"dropped": {State: Absent, ReassignTo: "dba"},
+				"dba":     {State: Present},
+				"other":   {State: Absent},
+			}.checkReassignTargets()).To(Succeed())
+			Ω(Roles{"dropped": {State: Absent, ReassignTo: "dropped"}}.checkReassignTargets()).NotTo(Succeed())
+			Ω(Roles{
+				"dropped": {State: Absent, ReassignTo: "dba"},
+				"dba":     {State: Absent},
+			}.checkReassignTargets()).NotTo(Succeed())
+		})
+	})
+})
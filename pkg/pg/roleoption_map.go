@@ -15,28 +15,20 @@ func (rom RoleOptionMap) ToList() RoleOptionList {
 // Clone will return a copy of the RoleOptionMap (keys are set to the string value of the option)
 func (rom RoleOptionMap) Clone() RoleOptionMap {
 	clone := RoleOptionMap{}
-	for opt := range rom {
-		clone[opt] = opt.Enabled()
+	for opt, enabled := range rom {
+		clone[opt] = enabled
 	}
 	return clone
 }
 
-// AbsoluteMerge can be used to merge one or more RoleOptionMaps to a single merged version
-// RoleOptions and their inverted counterpart are considered the same option and merged to one key, value pair.
-func (rom RoleOptionMap) AbsoluteMerge(other RoleOptionMap) RoleOptionMap {
-	merged := rom.Clone()
-	for opt := range other {
-		merged[opt.Absolute()] = opt.Enabled()
-	}
-	return merged
-}
-
 // Merge can be used to merge one or more RoleOptionMaps to a single merged version
-// RoleOptions and their inverted counterpart are considered different and merged to separate key, value pairs.
+// RoleOptions and their inverted counterpart are considered the same option and merged to one key, value pair.
+// Options in other take precedence, and the value of every option defines if the absolute option is enabled.
 func (rom RoleOptionMap) Merge(other RoleOptionMap) RoleOptionMap {
 	merged := rom.Clone()
-	for opt := range other {
-		merged[opt.Absolute()] = opt.Enabled()
+	for opt, enabled := range other {
+		delete(merged, opt.Absolute().Invert())
+		merged[opt.Absolute()] = enabled
 	}
 	return merged
 }
@@ -49,6 +41,46 @@ func (rom RoleOptionMap) AddAbsolute(opt RoleOption) RoleOptionMap {
 	return rom
 }
 
+// effective returns the options that should be set. The value of every option defines if the absolute option is
+// enabled, so SUPERUSER: false and NOSUPERUSER: false both become NOSUPERUSER.
+func (rom RoleOptionMap) effective() RoleOptionList {
+	effective := RoleOptionList{}
+	for _, opt := range AllNormalRoleOptions {
+		for _, key := range []RoleOption{opt, opt.Invert()} {
+			enabled, exists := rom[key]
+			if !exists {
+				continue
+			}
+			if enabled {
+				effective = append(effective, opt)
+			} else {
+				effective = append(effective, opt.Invert())
+			}
+			break
+		}
+	}
+	return effective
+}
+
+// defaultRoleOptions returns the PostgreSQL defaults for all role options (INHERIT, and the NO version of all other
+// options). These are used for roles with authoritative options.
+func defaultRoleOptions() RoleOptionMap {
+	defaults := RoleOptionMap{}
+	for _, opt := range AllNormalRoleOptions {
+		defaults[opt] = opt == RoleInherit
+	}
+	return defaults
+}
+
+// withDefaults returns a RoleOptionMap with all options, where options that are not in this map have their default
+func (rom RoleOptionMap) withDefaults() RoleOptionMap {
+	options := defaultRoleOptions()
+	for _, opt := range rom.effective() {
+		options[opt.Absolute()] = opt.Enabled()
+	}
+	return options
+}
+
 // IsEnabled checks an option in the
 func (rom RoleOptionMap) IsEnabled(opt RoleOption) bool {
 	enabled, exists := rom[opt]
@@ -0,0 +1,43 @@
+package pg
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/RoleOptionMap", func() {
+	Context("Clone", func() {
+		It("should keep disabled options", func() {
+			options := RoleOptionMap{}.AddAbsolute("NOSUPERUSER")
+			Ω(options.Clone()).To(Equal(RoleOptionMap{RoleSuperUser: false}))
+		})
+	})
+	Context("Merge", func() {
+		It("should let options of the other map take precedence", func() {
+			options := RoleOptionMap{"NOLOGIN": false, RoleCreateDB: true}
+			merged := options.Merge(RoleOptionMap{}.AddAbsolute(RoleLogin).AddAbsolute("NOCREATEDB"))
+			Ω(merged).To(Equal(RoleOptionMap{RoleLogin: true, RoleCreateDB: false}))
+			Ω(merged.effective()).To(Equal(RoleOptionList{RoleLogin, "NOCREATEDB"}))
+		})
+	})
+	Context("effective", func() {
+		It("should return the option that is effectively set", func() {
+			options := RoleOptionMap{RoleSuperUser: false, "NOCREATEDB": false, RoleLogin: true}
+			Ω(options.effective()).To(Equal(RoleOptionList{"NOSUPERUSER", RoleLogin, "NOCREATEDB"}))
+		})
+	})
+	Context("withDefaults", func() {
+		It("should set all undefined options to their default", func() {
+			options := RoleOptionMap{}.AddAbsolute(RoleLogin).AddAbsolute(RoleCreateDB)
+			Ω(options.withDefaults().effective()).To(Equal(RoleOptionList{
+				"NOSUPERUSER",
+				RoleLogin,
+				"NOCREATEROLE",
+				RoleCreateDB,
+				RoleInherit,
+				"NOREPLICATION",
+				"NOBYPASSRLS",
+			}))
+		})
+	})
+})
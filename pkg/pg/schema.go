@@ -26,6 +26,8 @@ type Schema struct {
 	name  string
 	Owner string `yaml:"owner"`
 	State State  `yaml:"state"`
+	// PreviousNames are renamed to the name of the schema when it does not exist, but a previous name does
+	PreviousNames []string `yaml:"previous_names"`
 }
 
 // reconcile can be used to grant or revoke all Roles.
@@ -55,12 +57,16 @@ func (s *Schema) drop(dbConn *Conn) (err error) {
 		log.Debugf("Schema '%s'.'%s' already gone.", dbConn.DBName(), s.name)
 		return nil
 	}
+	managed := ManagedObject{Kind: ObjectKindSchema, Database: dbConn.DBName(), Name: s.name}
+	if mayDrop, err := dbConn.mayDrop(managed); err != nil || !mayDrop {
+		return err
+	}
 	err = dbConn.runQueryExec("DROP SCHEMA " + identifier(s.name))
 	if err != nil {
 		return err
 	}
 	log.Infof("Schema '%s'.'%s' successfully dropped.", dbConn.DBName(), s.name)
-	return nil
+	return dbConn.unregisterManaged(managed)
 }
 
 func (s Schema) exists(conn *Conn) (exists bool, err error) {
@@ -78,7 +84,10 @@ func (s Schema) create(conn *Conn) (err error) {
 	}
 	if exists {
 		log.Debugf("Schema '%s'.'%s' already exists.", conn.DBName(), s.name)
-		return nil
+		return conn.adoptManaged(ManagedObject{Kind: ObjectKindSchema, Database: conn.DBName(), Name: s.name})
+	}
+	if renamed, err := s.renameFromPrevious(conn); err != nil || renamed {
+		return err
 	}
 	createQry := "CREATE SCHEMA " + identifier(s.name)
 	err = conn.runQueryExec(createQry)
@@ -86,7 +95,7 @@ func (s Schema) create(conn *Conn) (err error) {
 		return err
 	}
 	log.Infof("Schema '%s'.'%s' successfully created.", conn.DBName(), s.name)
-	return nil
+	return conn.registerManaged(ManagedObject{Kind: ObjectKindSchema, Database: conn.DBName(), Name: s.name})
 }
 
 func (s Schema) currentOwner(conn *Conn) (curOwner string, err error) {
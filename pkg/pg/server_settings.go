@@ -0,0 +1,220 @@
+package pg
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// preloadLibraries are the libraries that known extensions require in shared_preload_libraries.
+// Extensions that are not in this list can define their library with preload_library.
+var preloadLibraries = map[string]string{
+	"citus":              "citus",
+	"pg_cron":            "pg_cron",
+	"pg_qualstats":       "pg_qualstats",
+	"pg_squeeze":         "pg_squeeze",
+	"pg_stat_kcache":     "pg_stat_kcache",
+	"pg_stat_monitor":    "pg_stat_monitor",
+	"pg_stat_statements": "pg_stat_statements",
+	"pg_wait_sampling":   "pg_wait_sampling",
+	"pgaudit":            "pgaudit",
+	"pglogical":          "pglogical",
+	"timescaledb":        "timescaledb",
+}
+
+// preloadStatus describes if a library that an extension requires is preloaded
+type preloadStatus int
+
+const (
+	// preloadLoaded means that the library is loaded (or no library is required)
+	preloadLoaded preloadStatus = iota
+	// preloadPendingRestart means that the library is configured, but only loaded after a restart
+	preloadPendingRestart
+	// preloadMissing means that the library is not configured in shared_preload_libraries
+	preloadMissing
+)
+
+// normalizedLibrary returns the name of a library without a directory and extension (e.a. $libdir/pgaudit.so becomes
+// pgaudit)
+func normalizedLibrary(library string) string {
+	return strings.TrimSuffix(path.Base(library), ".so")
+}
+
+// containsLibrary returns true if library is in the list of libraries
+func containsLibrary(libraries []string, library string) bool {
+	return slices.ContainsFunc(libraries, func(item string) bool {
+		return normalizedLibrary(item) == normalizedLibrary(library)
+	})
+}
+
+// preloadStatusOf returns the status of a library, from the libraries that are loaded (the running value of
+// shared_preload_libraries) and the libraries that are configured (in the configuration files)
+func preloadStatusOf(library string, loaded []string, configured []string) preloadStatus {
+	switch {
+	case library == "" || containsLibrary(loaded, library):
+		return preloadLoaded
+	case containsLibrary(configured, library):
+		return preloadPendingRestart
+	default:
+		return preloadMissing
+	}
+}
+
+// configuredPreloadLibraries returns the libraries in shared_preload_libraries as set in the configuration files
+// (which are only loaded after a restart)
+func configuredPreloadLibraries(conn *Conn) (libraries []string, err error) {
+	values, err := conn.runQueryGetOneColumn(
+		`SELECT setting FROM pg_file_settings
+		WHERE name = 'shared_preload_libraries' AND error IS NULL
+		ORDER BY seqno DESC LIMIT 1`)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+	return splitListSetting(values[0]), nil
+}
+
+// checkPreload warns when the library that this extension requires is not preloaded. When preload libraries are
+// strict, a library that is not configured in shared_preload_libraries at all is an error.
+func (e Extension) checkPreload(conn *Conn) (err error) {
+	library := e.preloadLibrary()
+	if library == "" {
+		return nil
+	}
+	running, err := conn.runQueryGetOneField("SELECT current_setting('shared_preload_libraries')")
+	if err != nil {
+		return err
+	}
+	configured, err := configuredPreloadLibraries(conn)
+	if err != nil {
+		return err
+	}
+	switch preloadStatusOf(library, splitListSetting(running), configured) {
+	case preloadPendingRestart:
+		log.Warnf("Extension '%s'.'%s' requires library '%s', which is only loaded after a restart of PostgreSQL",
+			conn.DBName(), e.name, library)
+	case preloadMissing:
+		if e.strictPreload {
+			return fmt.Errorf("extension %s requires library %s in shared_preload_libraries", e.name, library)
+		}
+		log.Warnf("Extension '%s'.'%s' requires library '%s', which is not in shared_preload_libraries",
+			conn.DBName(), e.name, library)
+	}
+	return nil
+}
+
+// preloadLibrary returns the library that this extension requires in shared_preload_libraries (if any)
+func (e Extension) preloadLibrary() string {
+	if e.PreloadLibrary != "" {
+		return e.PreloadLibrary
+	}
+	return preloadLibraries[e.name]
+}
+
+// systemSettings returns the settings that are set with ALTER SYSTEM (in postgresql.auto.conf)
+func systemSettings(conn Conn) (settings Settings, err error) {
+	pgSettings, err := conn.runQueryGetOneColumn(
+		`SELECT name || '=' || setting FROM pg_file_settings
+		WHERE sourcefile LIKE '%postgresql.auto.conf'
+		ORDER BY seqno`)
+	if err != nil {
+		return nil, err
+	}
+	return settingsFromPg(pgSettings), nil
+}
+
+// pendingRestart returns all settings that only take effect after a restart of PostgreSQL. Settings in changed that
+// can only be set at server start are always returned, since pending_restart is only updated after the reload.
+func pendingRestart(conn Conn, changed []string) (pending []string, err error) {
+	if changed == nil {
+		changed = []string{}
+	}
+	return conn.runQueryGetOneColumn(
+		`SELECT name FROM pg_settings
+		WHERE pending_restart OR (context = 'postmaster' AND name = ANY($1))
+		ORDER BY name`,
+		changed)
+}
+
+// reconcileServerSettings sets all server settings that differ from the config with ALTER SYSTEM, reloads the
+// configuration when something changed, and warns about settings that require a restart.
+// Settings that are set with ALTER SYSTEM, but are not in the config, are left as they are.
+func (h *Handler) reconcileServerSettings(primaryConn Conn) (err error) {
+	if len(h.ServerSettings) == 0 {
+		return nil
+	}
+	current, err := systemSettings(primaryConn)
+	if err != nil {
+		return err
+	}
+	var changed []string
+	for _, key := range slices.Sorted(maps.Keys(h.ServerSettings)) {
+		if curValue, exists := current[key]; exists && h.ServerSettings.equal(key, curValue) {
+			continue
+		}
+		err = primaryConn.runQueryExec(
+			fmt.Sprintf("ALTER SYSTEM SET %s = %s", identifier(key), h.ServerSettings.valueSQL(key)))
+		if err != nil {
+			return err
+		}
+		log.Infof("Server setting '%s' successfully set to '%s'", key, h.ServerSettings[key])
+		changed = append(changed, key)
+	}
+	if len(changed) > 0 {
+		if err = primaryConn.runQueryExec("SELECT pg_reload_conf()"); err != nil {
+			return err
+		}
+		log.Infof("Configuration successfully reloaded")
+	}
+	pending, err := pendingRestart(primaryConn, changed)
+	if err != nil {
+		return err
+	}
+	for _, key := range pending {
+		log.Warnf("Server setting '%s' only takes effect after a restart of PostgreSQL", key)
+	}
+	return nil
+}
+
+// ServerSettingStatus describes a server setting from the config, with the value that PostgreSQL is running with
+type ServerSettingStatus struct {
+	Name       string
+	Configured string
+	// Running is the value that PostgreSQL is currently using (empty for unknown settings)
+	Running        string
+	PendingRestart bool
+}
+
+// ServerSettingsReport returns the status of all server settings in the config, and of all other settings that
+// are pending a restart
+func (h *Handler) ServerSettingsReport() (statuses []ServerSettingStatus, err error) {
+	conn := h.getPrimaryConnection()
+	if err = conn.Connect(); err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	pending, err := pendingRestart(conn, nil)
+	if err != nil {
+		return nil, err
+	}
+	names := slices.Sorted(maps.Keys(h.ServerSettings))
+	for _, name := range pending {
+		if _, configured := h.ServerSettings[name]; !configured {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		running, err := conn.runQueryGetOneField("SELECT COALESCE(current_setting($1, true), '')", name)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, ServerSettingStatus{
+			Name:           name,
+			Configured:     h.ServerSettings[name],
+			Running:        running,
+			PendingRestart: slices.Contains(pending, name),
+		})
+	}
+	return statuses, nil
+}
@@ -0,0 +1,45 @@
+package pg
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/ServerSettings", func() {
+	Context("normalizedLibrary", func() {
+		It("should strip the directory and extension of a library", func() {
+			for input, expected := range map[string]string{
+				"pgaudit":            "pgaudit",
+				"$libdir/pgaudit":    "pgaudit",
+				"$libdir/pgaudit.so": "pgaudit",
+				"pg_stat_statements": "pg_stat_statements",
+			} {
+				Ω(normalizedLibrary(input)).To(Equal(expected), input)
+			}
+		})
+	})
+	Context("preloadStatusOf", func() {
+		It("should find loaded, configured and missing libraries", func() {
+			loaded := []string{"$libdir/pg_stat_statements"}
+			configured := []string{"pg_stat_statements", "pg_cron.so"}
+			Ω(preloadStatusOf("", nil, nil)).To(Equal(preloadLoaded))
+			Ω(preloadStatusOf("pg_stat_statements", loaded, configured)).To(Equal(preloadLoaded))
+			Ω(preloadStatusOf("pg_cron", loaded, configured)).To(Equal(preloadPendingRestart))
+			Ω(preloadStatusOf("pgaudit", loaded, configured)).To(Equal(preloadMissing))
+		})
+	})
+	Context("preloadLibrary", func() {
+		It("should default to the library of known extensions", func() {
+			Ω(Extension{name: "pg_stat_statements"}.preloadLibrary()).To(Equal("pg_stat_statements"))
+			Ω(Extension{name: "hstore"}.preloadLibrary()).To(BeEmpty())
+			Ω(Extension{name: "my_ext", PreloadLibrary: "my_lib"}.preloadLibrary()).To(Equal("my_lib"))
+		})
+	})
+	Context("shared_preload_libraries", func() {
+		It("should be handled as a list setting", func() {
+			settings := Settings{"shared_preload_libraries": "pg_stat_statements, pg_cron"}
+			Ω(settings.equal("shared_preload_libraries", "pg_stat_statements,pg_cron")).To(BeTrue())
+			Ω(settings.valueSQL("shared_preload_libraries")).To(Equal(`"pg_stat_statements", "pg_cron"`))
+		})
+	})
+})
@@ -0,0 +1,91 @@
+package pg
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultForceGracePeriod = 10 * time.Second
+	forcePollInterval       = time.Second
+)
+
+// ForceOptions define if sessions are terminated when dropping a database or a role fails.
+// Before sessions are terminated, new sessions are blocked and existing sessions get a grace period to finish.
+type ForceOptions struct {
+	Enabled     bool          `yaml:"enabled"`
+	GracePeriod time.Duration `yaml:"grace_period"`
+}
+
+// UnmarshalYAML allows force to be set as a boolean or as a map with a grace period
+func (fo *ForceOptions) UnmarshalYAML(unmarshal func(any) error) error {
+	var enabled bool
+	if err := unmarshal(&enabled); err == nil {
+		*fo = ForceOptions{Enabled: enabled}
+		return nil
+	}
+	type plain ForceOptions
+	if err := unmarshal((*plain)(fo)); err != nil {
+		return err
+	}
+	if fo.GracePeriod < 0 {
+		return fmt.Errorf("invalid grace_period %s (should not be negative)", fo.GracePeriod)
+	}
+	return nil
+}
+
+func (fo ForceOptions) gracePeriod() time.Duration {
+	if fo.GracePeriod == 0 {
+		return defaultForceGracePeriod
+	}
+	return fo.GracePeriod
+}
+
+// sessionsOf selects the sessions in pg_stat_activity that are connected to a database, or as a role
+type sessionsOf string
+
+const (
+	sessionsOfDatabase sessionsOf = "datname"
+	sessionsOfRole     sessionsOf = "usename"
+)
+
+func (so sessionsOf) count(conn Conn, name string) (count int, err error) {
+	answer, err := conn.runQueryGetOneField(
+		fmt.Sprintf("SELECT count(*)::text FROM pg_stat_activity WHERE %s = $1 AND pid != pg_backend_pid()", so),
+		name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(answer)
+}
+
+// terminate waits for all sessions to end within the grace period, and terminates the sessions that remain
+func (so sessionsOf) terminate(conn Conn, name string, gracePeriod time.Duration) (err error) {
+	deadline := time.Now().Add(gracePeriod)
+	for {
+		count, err := so.count(conn, name)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		log.Infof("Waiting for %d sessions of '%s' to end", count, name)
+		time.Sleep(forcePollInterval)
+	}
+	terminated, err := conn.runQueryGetOneColumn(
+		fmt.Sprintf(`SELECT pid::text FROM pg_stat_activity
+			WHERE %s = $1 AND pid != pg_backend_pid() AND pg_terminate_backend(pid)`, so),
+		name)
+	if err != nil {
+		return err
+	}
+	for _, pid := range terminated {
+		log.Infof("Terminated session with pid %s of '%s'", pid, name)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package pg
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+var _ = Describe("Pkg/Pg/Sessions", func() {
+	Context("ForceOptions", func() {
+		It("should accept a boolean", func() {
+			var fo ForceOptions
+			Ω(yaml.Unmarshal([]byte("true"), &fo)).To(Succeed())
+			Ω(fo.Enabled).To(BeTrue())
+			Ω(fo.gracePeriod()).To(Equal(defaultForceGracePeriod))
+		})
+		It("should accept a map with a grace period", func() {
+			var fo ForceOptions
+			Ω(yaml.Unmarshal([]byte("enabled: true\ngrace_period: 30s\n"), &fo)).To(Succeed())
+			Ω(fo.Enabled).To(BeTrue())
+			Ω(fo.gracePeriod()).To(Equal(30 * time.Second))
+		})
+		It("should refuse a negative grace period", func() {
+			var fo ForceOptions
+			Ω(yaml.Unmarshal([]byte("enabled: true\ngrace_period: -1s\n"), &fo)).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,145 @@
+package pg
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// listSettings are settings that hold a list of values (e.a. search_path = "$user", public).
+// Every item is set as a separate value, so that PostgreSQL stores them as a list.
+var listSettings = map[string]bool{
+	"search_path":               true,
+	"temp_tablespaces":          true,
+	"local_preload_libraries":   true,
+	"session_preload_libraries": true,
+	"shared_preload_libraries":  true,
+}
+
+// Settings holds configuration parameters (e.a. statement_timeout) as key, value pairs.
+// Settings can be set on databases, roles and on roles in a specific database.
+// A nil Settings map means that settings are unmanaged, an empty map means that all settings should be reset.
+type Settings map[string]string
+
+// settingsFromPg parses settings as stored in pg_db_role_setting.setconfig (e.a. statement_timeout=30s)
+func settingsFromPg(pgSettings []string) Settings {
+	settings := Settings{}
+	for _, pgSetting := range pgSettings {
+		key, value, _ := strings.Cut(pgSetting, "=")
+		settings[key] = value
+	}
+	return settings
+}
+
+// splitListSetting returns the normalized items of a list setting
+func splitListSetting(value string) (items []string) {
+	for item := range strings.SplitSeq(value, ",") {
+		item = strings.Trim(strings.TrimSpace(item), `"`)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// equal returns true if the value of the setting in this map equals the (PostgreSQL stored) value
+func (s Settings) equal(key string, value string) bool {
+	expected, exists := s[key]
+	if !exists {
+		return false
+	}
+	if listSettings[key] {
+		return slices.Equal(splitListSetting(expected), splitListSetting(value))
+	}
+	return expected == value
+}
+
+// valueSQL returns the SQL part to set the value of this setting (e.a. `'30s'`)
+func (s Settings) valueSQL(key string) string {
+	if !listSettings[key] {
+		return quotedSQLValue(s[key])
+	}
+	var items []string
+	for _, item := range splitListSetting(s[key]) {
+		items = append(items, identifier(item))
+	}
+	if len(items) == 0 {
+		return "''"
+	}
+	return strings.Join(items, ", ")
+}
+
+// settingsTarget points to the database, the role or the role in a specific database that settings are set on
+type settingsTarget struct {
+	role     string
+	database string
+}
+
+func (st settingsTarget) String() string {
+	switch {
+	case st.database == "":
+		return fmt.Sprintf("role '%s'", st.role)
+	case st.role == "":
+		return fmt.Sprintf("database '%s'", st.database)
+	default:
+		return fmt.Sprintf("role '%s' in database '%s'", st.role, st.database)
+	}
+}
+
+// alterSQL returns the first part of the ALTER statement for this target
+func (st settingsTarget) alterSQL() string {
+	switch {
+	case st.database == "":
+		return "ALTER ROLE " + identifier(st.role)
+	case st.role == "":
+		return "ALTER DATABASE " + identifier(st.database)
+	default:
+		return fmt.Sprintf("ALTER ROLE %s IN DATABASE %s", identifier(st.role), identifier(st.database))
+	}
+}
+
+func (st settingsTarget) currentSettings(conn Conn) (settings Settings, err error) {
+	pgSettings, err := conn.runQueryGetOneColumn(
+		`SELECT unnest(setconfig) FROM pg_db_role_setting
+		WHERE setrole = COALESCE((SELECT oid FROM pg_roles WHERE rolname = $1), 0)
+		AND setdatabase = COALESCE((SELECT oid FROM pg_database WHERE datname = $2), 0)`,
+		st.role,
+		st.database)
+	if err != nil {
+		return nil, err
+	}
+	return settingsFromPg(pgSettings), nil
+}
+
+// reconcile sets all settings that differ from the config, and resets all settings that are not in the config
+func (st settingsTarget) reconcile(conn Conn, settings Settings) (err error) {
+	if settings == nil {
+		return nil
+	}
+	current, err := st.currentSettings(conn)
+	if err != nil {
+		return err
+	}
+	for _, key := range slices.Sorted(maps.Keys(settings)) {
+		if curValue, exists := current[key]; exists && settings.equal(key, curValue) {
+			continue
+		}
+		err = conn.runQueryExec(fmt.Sprintf("%s SET %s = %s", st.alterSQL(), identifier(key), settings.valueSQL(key)))
+		if err != nil {
+			return err
+		}
+		log.Infof("Setting '%s' successfully set to '%s' for %s", key, settings[key], st)
+	}
+	for _, key := range slices.Sorted(maps.Keys(current)) {
+		if _, exists := settings[key]; exists {
+			continue
+		}
+		err = conn.runQueryExec(fmt.Sprintf("%s RESET %s", st.alterSQL(), identifier(key)))
+		if err != nil {
+			return err
+		}
+		log.Infof("Setting '%s' successfully reset for %s", key, st)
+	}
+	return nil
+}
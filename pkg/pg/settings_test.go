@@ -0,0 +1,40 @@
+package pg
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/Settings", func() {
+	Context("settingsFromPg", func() {
+		It("should parse settings as stored in pg_db_role_setting", func() {
+			settings := settingsFromPg([]string{"statement_timeout=30s", `search_path="$user", public`})
+			Ω(settings).To(Equal(Settings{"statement_timeout": "30s", "search_path": `"$user", public`}))
+		})
+	})
+	Context("equal", func() {
+		It("should compare regular and list settings", func() {
+			settings := Settings{"statement_timeout": "30s", "search_path": "$user,public"}
+			Ω(settings.equal("statement_timeout", "30s")).To(BeTrue())
+			Ω(settings.equal("statement_timeout", "1min")).To(BeFalse())
+			Ω(settings.equal("search_path", `"$user", public`)).To(BeTrue())
+			Ω(settings.equal("search_path", "public")).To(BeFalse())
+			Ω(settings.equal("work_mem", "4MB")).To(BeFalse())
+		})
+	})
+	Context("valueSQL", func() {
+		It("should quote regular and list settings", func() {
+			settings := Settings{"pgaudit.log": "read, write", "search_path": `"$user", public`}
+			Ω(settings.valueSQL("pgaudit.log")).To(Equal(`'read, write'`))
+			Ω(settings.valueSQL("search_path")).To(Equal(`"$user", "public"`))
+		})
+	})
+	Context("settingsTarget", func() {
+		It("should build the proper ALTER statement", func() {
+			Ω(settingsTarget{role: "app"}.alterSQL()).To(Equal(`ALTER ROLE "app"`))
+			Ω(settingsTarget{database: "db"}.alterSQL()).To(Equal(`ALTER DATABASE "db"`))
+			Ω(settingsTarget{role: "app", database: "db"}.alterSQL()).To(
+				Equal(`ALTER ROLE "app" IN DATABASE "db"`))
+		})
+	})
+})
@@ -0,0 +1,274 @@
+package pg
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pgvillage-tools/pgfga/pkg/duration"
+)
+
+// ByteSize is an amount of bytes, which can be set in yaml as a number or with a unit (e.a. 10GB).
+// Like in PostgreSQL, units are multiples of 1024.
+type ByteSize int64
+
+var byteSizeUnits = map[string]int64{
+	"":   1,
+	"b":  1,
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+	"tb": 1 << 40,
+}
+
+var byteSizeRe = regexp.MustCompile(`^\s*([0-9]+)\s*([a-zA-Z]*)\s*$`)
+
+// ParseByteSize converts a size (e.a. 512MB) to a ByteSize
+func ParseByteSize(size string) (ByteSize, error) {
+	match := byteSizeRe.FindStringSubmatch(size)
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %s (should be a number with an optional unit like 10GB)", size)
+	}
+	unit, exists := byteSizeUnits[strings.ToLower(match[2])]
+	if !exists {
+		return 0, fmt.Errorf("invalid unit %s in size %s (should be B, kB, MB, GB or TB)", match[2], size)
+	}
+	value, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return ByteSize(value * unit), nil
+}
+
+// UnmarshalYAML converts a yaml number or size string to a ByteSize
+func (bs *ByteSize) UnmarshalYAML(unmarshal func(any) error) error {
+	var size string
+	if err := unmarshal(&size); err != nil {
+		return err
+	}
+	parsed, err := ParseByteSize(size)
+	if err != nil {
+		return err
+	}
+	*bs = parsed
+	return nil
+}
+
+// GuardrailAction defines what pgfga does with a replication slot that exceeds a guardrail
+type GuardrailAction string
+
+const (
+	// GuardrailActionWarn logs a warning (default)
+	GuardrailActionWarn GuardrailAction = "warn"
+	// GuardrailActionFlag logs an error that the slot requires manual action
+	GuardrailActionFlag GuardrailAction = "flag"
+	// GuardrailActionDrop drops the slot when it is inactive
+	GuardrailActionDrop GuardrailAction = "drop"
+)
+
+// SlotGuardrails define thresholds for replication slots. A slot that retains more WAL, or that is inactive for
+// longer than allowed, exceeds the guardrails.
+type SlotGuardrails struct {
+	// MaxRetainedWAL is the maximum amount of WAL that a slot may retain (0 means no maximum)
+	MaxRetainedWAL ByteSize `yaml:"max_retained_wal"`
+	// MaxInactive is the maximum time a slot may be inactive, and can be set in days (e.a. 7d). 0 means no maximum.
+	// It requires PostgreSQL 17 or newer.
+	MaxInactive duration.Duration `yaml:"max_inactive"`
+	// Action defines what happens with slots that exceed the guardrails
+	Action GuardrailAction `yaml:"action"`
+}
+
+// Validate returns an error for an invalid action
+func (sg SlotGuardrails) Validate() error {
+	switch sg.Action {
+	case "", GuardrailActionWarn, GuardrailActionFlag, GuardrailActionDrop:
+		return nil
+	}
+	return fmt.Errorf("invalid guardrail action %s (should be warn, flag or drop)", sg.Action)
+}
+
+// merge returns these guardrails, with the values that are set in other taking precedence
+func (sg SlotGuardrails) merge(other SlotGuardrails) SlotGuardrails {
+	if other.MaxRetainedWAL != 0 {
+		sg.MaxRetainedWAL = other.MaxRetainedWAL
+	}
+	if other.MaxInactive != 0 {
+		sg.MaxInactive = other.MaxInactive
+	}
+	if other.Action != "" {
+		sg.Action = other.Action
+	}
+	return sg
+}
+
+func (sg SlotGuardrails) action() GuardrailAction {
+	if sg.Action == "" {
+		return GuardrailActionWarn
+	}
+	return sg.Action
+}
+
+// violations returns a description of every guardrail that a slot exceeds
+func (sg SlotGuardrails) violations(status SlotStatus, now time.Time) (violations []string) {
+	if sg.MaxRetainedWAL > 0 && status.RetainedWAL > int64(sg.MaxRetainedWAL) {
+		violations = append(violations, fmt.Sprintf("retains %d bytes of WAL (max %d)",
+			status.RetainedWAL, sg.MaxRetainedWAL))
+	}
+	if sg.MaxInactive > 0 && !status.Active && !status.InactiveSince.IsZero() {
+		if inactive := now.Sub(status.InactiveSince); inactive > sg.MaxInactive.Duration() {
+			violations = append(violations, fmt.Sprintf("inactive for %s (max %s)",
+				inactive.Round(time.Second), sg.MaxInactive.Duration()))
+		}
+	}
+	return violations
+}
+
+// SlotStatus is the status of a replication slot, as read from pg_replication_slots
+type SlotStatus struct {
+	Name        string
+	Type        SlotType
+	Database    string
+	Active      bool
+	RetainedWAL int64
+	// InactiveSince is only set for inactive slots on PostgreSQL 17 and newer
+	InactiveSince time.Time
+	// Managed is true if the slot is defined in the config
+	Managed    bool
+	Violations []string
+	Action     GuardrailAction
+}
+
+// slotStatuses returns the status of all replication slots
+func slotStatuses(conn Conn) (statuses []SlotStatus, err error) {
+	serverVersion, err := conn.serverVersion()
+	if err != nil {
+		return nil, err
+	}
+	inactiveSince := "NULL::timestamptz"
+	if serverVersion >= pg17VersionNum {
+		inactiveSince = "inactive_since"
+	}
+	rows, err := conn.conn.Query(conn.ctx, fmt.Sprintf(
+		`SELECT slot_name, slot_type, coalesce(database, ''), active,
+			coalesce(pg_wal_lsn_diff(CASE WHEN pg_is_in_recovery() THEN pg_last_wal_receive_lsn()
+				ELSE pg_current_wal_lsn() END, restart_lsn), 0)::bigint,
+			coalesce(%s, '-infinity'::timestamptz)
+		FROM pg_replication_slots
+		WHERE NOT temporary
+		ORDER BY slot_name`, inactiveSince))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status SlotStatus
+		var slotType string
+		var since time.Time
+		if err = rows.Scan(&status.Name, &slotType, &status.Database, &status.Active, &status.RetainedWAL,
+			&since); err != nil {
+			return nil, err
+		}
+		status.Type = SlotType(slotType)
+		if since.Year() > 1 {
+			status.InactiveSince = since
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
+
+// SlotReport returns the status of all replication slots, including slots that are not defined in the config,
+// and the guardrails they exceed
+func (h *Handler) SlotReport() (statuses []SlotStatus, err error) {
+	conn := h.getPrimaryConnection()
+	if err = conn.Connect(); err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	statuses, err = slotStatuses(conn)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i, status := range statuses {
+		slot, managed := h.Slots[status.Name]
+		guardrails := h.SlotGuardrails.merge(slot.Guardrails)
+		statuses[i].Managed = managed
+		statuses[i].Violations = guardrails.violations(status, now)
+		statuses[i].Action = guardrails.action()
+		if !managed {
+			// Unmanaged slots are only reported, pgfga never acts on them
+			statuses[i].Action = GuardrailActionWarn
+		}
+	}
+	return statuses, nil
+}
+
+// checkSlotGuardrails logs all slots that are not defined in the config, and warns about, flags or drops slots that
+// exceed their guardrails
+func (h *Handler) checkSlotGuardrails(primaryConn Conn) (err error) {
+	if err = h.SlotGuardrails.Validate(); err != nil {
+		return err
+	}
+	for _, slotName := range slices.Sorted(maps.Keys(h.Slots)) {
+		if err = h.Slots[slotName].Guardrails.Validate(); err != nil {
+			return fmt.Errorf("replication slot %s: %w", slotName, err)
+		}
+	}
+	if err = h.checkMaxInactiveSupported(primaryConn); err != nil {
+		return err
+	}
+	statuses, err := h.SlotReport()
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if !status.Managed {
+			log.Infof("Replication slot '%s' is not defined in the config", status.Name)
+		}
+		if len(status.Violations) == 0 {
+			continue
+		}
+		violations := strings.Join(status.Violations, ", ")
+		switch status.Action {
+		case GuardrailActionFlag:
+			log.Errorf("Replication slot '%s' requires manual action: %s", status.Name, violations)
+		case GuardrailActionDrop:
+			if status.Active {
+				log.Warnf("Replication slot '%s' is not dropped, because it is active: %s", status.Name, violations)
+				continue
+			}
+			log.Warnf("Dropping replication slot '%s': %s", status.Name, violations)
+			if err = (ReplicationSlot{name: status.Name, State: Absent}).drop(primaryConn); err != nil {
+				return err
+			}
+		default:
+			log.Warnf("Replication slot '%s' %s", status.Name, violations)
+		}
+	}
+	return nil
+}
+
+// checkMaxInactiveSupported warns (once) when max_inactive is set, but PostgreSQL is older than 17 (which does not
+// track since when a slot is inactive)
+func (h *Handler) checkMaxInactiveSupported(primaryConn Conn) (err error) {
+	configured := h.SlotGuardrails.MaxInactive > 0
+	for _, slot := range h.Slots {
+		configured = configured || slot.Guardrails.MaxInactive > 0
+	}
+	if !configured {
+		return nil
+	}
+	serverVersion, err := primaryConn.serverVersion()
+	if err != nil {
+		return err
+	}
+	if serverVersion < pg17VersionNum {
+		log.Warnf("Slot guardrail max_inactive is ignored, because it requires PostgreSQL 17 or newer")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package pg
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pgvillage-tools/pgfga/pkg/duration"
+	"gopkg.in/yaml.v2"
+)
+
+var _ = Describe("Pkg/Pg/SlotGuardrails", func() {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	Context("ByteSize", func() {
+		It("should parse numbers and sizes with a unit", func() {
+			for input, expected := range map[string]ByteSize{
+				"1024":  1024,
+				"10kB":  10 << 10,
+				"512MB": 512 << 20,
+				"10 GB": 10 << 30,
+				"1tb":   1 << 40,
+			} {
+				Ω(ParseByteSize(input)).To(Equal(expected), input)
+			}
+			for _, invalid := range []string{"", "GB", "10 PB", "1.5GB"} {
+				_, err := ParseByteSize(invalid)
+				Ω(err).To(HaveOccurred(), invalid)
+			}
+		})
+		It("should unmarshal from yaml", func() {
+			var guardrails SlotGuardrails
+			Ω(yaml.Unmarshal([]byte("max_retained_wal: 10GB\nmax_inactive: 7d\naction: drop\n"), &guardrails)).To(
+				Succeed())
+			Ω(guardrails).To(Equal(SlotGuardrails{
+				MaxRetainedWAL: 10 << 30, MaxInactive: duration.Duration(7 * 24 * time.Hour), Action: GuardrailActionDrop,
+			}))
+			Ω(yaml.Unmarshal([]byte("max_retained_wal: 2048\n"), &guardrails)).To(Succeed())
+			Ω(guardrails.MaxRetainedWAL).To(Equal(ByteSize(2048)))
+		})
+	})
+	Context("Validate", func() {
+		It("should only accept known actions", func() {
+			Ω(SlotGuardrails{}.Validate()).To(Succeed())
+			Ω(SlotGuardrails{Action: GuardrailActionFlag}.Validate()).To(Succeed())
+			Ω(SlotGuardrails{Action: "delete"}.Validate()).NotTo(Succeed())
+		})
+	})
+	Context("merge", func() {
+		It("should let slot guardrails override global guardrails", func() {
+			global := SlotGuardrails{MaxRetainedWAL: 1 << 30, MaxInactive: duration.Duration(time.Hour)}
+			merged := global.merge(SlotGuardrails{MaxInactive: duration.Duration(2 * time.Hour), Action: GuardrailActionFlag})
+			Ω(merged).To(Equal(SlotGuardrails{
+				MaxRetainedWAL: 1 << 30, MaxInactive: duration.Duration(2 * time.Hour), Action: GuardrailActionFlag,
+			}))
+			Ω(global.action()).To(Equal(GuardrailActionWarn))
+		})
+	})
+	Context("violations", func() {
+		guardrails := SlotGuardrails{MaxRetainedWAL: 1 << 20, MaxInactive: duration.Duration(time.Hour)}
+		It("should report slots that retain too much WAL or are inactive too long", func() {
+			Ω(guardrails.violations(SlotStatus{Active: true, RetainedWAL: 1 << 20}, now)).To(BeEmpty())
+			Ω(guardrails.violations(SlotStatus{Active: true, RetainedWAL: 2 << 20}, now)).To(
+				Equal([]string{"retains 2097152 bytes of WAL (max 1048576)"}))
+			Ω(guardrails.violations(SlotStatus{InactiveSince: now.Add(-2 * time.Hour)}, now)).To(
+				Equal([]string{"inactive for 2h0m0s (max 1h0m0s)"}))
+		})
+		It("should ignore the inactive time when it is unknown", func() {
+			Ω(guardrails.violations(SlotStatus{}, now)).To(BeEmpty())
+			Ω(SlotGuardrails{}.violations(SlotStatus{RetainedWAL: 1 << 40}, now)).To(BeEmpty())
+		})
+	})
+})
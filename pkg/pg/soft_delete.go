@@ -0,0 +1,357 @@
+package pg
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	softDeleteInfix       = "_deleted_"
+	softDeleteDateFormat  = "20060102"
+	defaultRetentionDays  = 30
+	maxIdentifierLength   = 63
+	hoursPerDay           = 24
+	softDeletedDateDigits = len(softDeleteDateFormat)
+	// softDeleteMarker starts the comment that records when an object was soft deleted. The original comment (if any)
+	// follows on the next line.
+	softDeleteMarker = "pgfga: soft deleted at "
+)
+
+// SoftDelete defines that a database or role with state Absent is not dropped, but renamed to
+// <name>_deleted_<yyyymmdd> and disabled. The time of deletion is recorded in the comment of the object.
+// Soft deleted objects are dropped after the retention period on a later run, and are restored when they are defined
+// as Present again before that.
+type SoftDelete struct {
+	Enabled       bool `yaml:"enabled"`
+	RetentionDays int  `yaml:"retention_days"`
+}
+
+// UnmarshalYAML allows soft_delete to be set as a boolean or as a map with a retention period
+func (sd *SoftDelete) UnmarshalYAML(unmarshal func(any) error) error {
+	var enabled bool
+	if err := unmarshal(&enabled); err == nil {
+		*sd = SoftDelete{Enabled: enabled}
+		return nil
+	}
+	type plain SoftDelete
+	if err := unmarshal((*plain)(sd)); err != nil {
+		return err
+	}
+	if sd.RetentionDays < 0 {
+		return fmt.Errorf("invalid retention_days %d (should not be negative)", sd.RetentionDays)
+	}
+	return nil
+}
+
+func (sd SoftDelete) retention() time.Duration {
+	days := sd.RetentionDays
+	if days == 0 {
+		days = defaultRetentionDays
+	}
+	return time.Duration(days) * hoursPerDay * time.Hour
+}
+
+// expired returns true if an object that was soft deleted at deletedAt should be dropped
+func (sd SoftDelete) expired(deletedAt time.Time, now time.Time) bool {
+	return now.Sub(deletedAt) >= sd.retention()
+}
+
+// softDeletedName returns the name an object is renamed to when it is soft deleted
+func softDeletedName(name string, at time.Time) (string, error) {
+	deletedName := name + softDeleteInfix + at.UTC().Format(softDeleteDateFormat)
+	if len(deletedName) > maxIdentifierLength {
+		return "", fmt.Errorf("cannot soft delete %s: name is too long to rename to %s", name, deletedName)
+	}
+	return deletedName, nil
+}
+
+// softDeleteComment returns the comment that records the time of deletion, followed by the original comment
+func softDeleteComment(at time.Time, original string) string {
+	comment := softDeleteMarker + at.UTC().Format(time.RFC3339)
+	if original != "" {
+		comment += "\n" + original
+	}
+	return comment
+}
+
+// parseSoftDeleteComment returns the time of deletion and the original comment from a comment set by
+// softDeleteComment. ok is false when the comment was not set by softDeleteComment.
+func parseSoftDeleteComment(comment string) (deletedAt time.Time, original string, ok bool) {
+	if !strings.HasPrefix(comment, softDeleteMarker) {
+		return time.Time{}, "", false
+	}
+	stamp, original, _ := strings.Cut(strings.TrimPrefix(comment, softDeleteMarker), "\n")
+	deletedAt, err := time.Parse(time.RFC3339, stamp)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return deletedAt, original, true
+}
+
+// softDeletedCopy is an object that was renamed when it was soft deleted
+type softDeletedCopy struct {
+	name      string
+	deletedAt time.Time
+	// comment is the original comment of the object, from before it was soft deleted
+	comment string
+}
+
+// softDeletedCandidate is an object with a name that starts like a soft deleted copy, and its comment
+type softDeletedCandidate struct {
+	name    string
+	comment string
+}
+
+// softDeletedCopies returns all soft deleted copies of an object (newest first). The query should return the name
+// and comment of all objects with a name that starts with the name in $1.
+func softDeletedCopies(conn Conn, query string, name string) (copies []softDeletedCopy, err error) {
+	if err = conn.Connect(); err != nil {
+		return nil, err
+	}
+	rows, err := conn.conn.Query(conn.ctx, query, name+softDeleteInfix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var candidates []softDeletedCandidate
+	for rows.Next() {
+		var candidate softDeletedCandidate
+		if err = rows.Scan(&candidate.name, &candidate.comment); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return parseSoftDeletedCopies(name, candidates), nil
+}
+
+// parseSoftDeletedCopies returns the candidates that are soft deleted copies of an object (newest first). The time of
+// deletion is read from the comment, and from the date in the name for copies without a soft delete comment.
+func parseSoftDeletedCopies(name string, candidates []softDeletedCandidate) (copies []softDeletedCopy) {
+	re := regexp.MustCompile(fmt.Sprintf("^%s%s([0-9]{%d})$",
+		regexp.QuoteMeta(name), softDeleteInfix, softDeletedDateDigits))
+	for _, candidate := range candidates {
+		match := re.FindStringSubmatch(candidate.name)
+		if match == nil {
+			continue
+		}
+		if deletedAt, original, ok := parseSoftDeleteComment(candidate.comment); ok {
+			copies = append(copies, softDeletedCopy{name: candidate.name, deletedAt: deletedAt, comment: original})
+			continue
+		}
+		deletedAt, err := time.Parse(softDeleteDateFormat, match[1])
+		if err != nil {
+			continue
+		}
+		copies = append(copies, softDeletedCopy{name: candidate.name, deletedAt: deletedAt, comment: candidate.comment})
+	}
+	slices.SortFunc(copies, func(a, b softDeletedCopy) int {
+		return b.deletedAt.Compare(a.deletedAt)
+	})
+	return copies
+}
+
+const (
+	softDeletedDatabasesQuery = `SELECT datname, COALESCE(shobj_description(oid, 'pg_database'), '')
+	FROM pg_database WHERE left(datname, length($1)) = $1`
+	softDeletedRolesQuery = `SELECT rolname, COALESCE(shobj_description(oid, 'pg_authid'), '')
+	FROM pg_roles WHERE left(rolname, length($1)) = $1`
+)
+
+// commentSQL returns the value for COMMENT ON, which is NULL for an empty comment
+func commentSQL(comment string) string {
+	if comment == "" {
+		return "NULL"
+	}
+	return quotedSQLValue(comment)
+}
+
+// softDelete blocks connections to the database, renames it and records the time of deletion in its comment.
+// It also drops soft deleted copies after the retention period.
+func (d Database) softDelete(conn Conn) (err error) {
+	exists, err := d.exists(conn)
+	if err != nil {
+		return err
+	}
+	if exists {
+		managed := ManagedObject{Kind: ObjectKindDatabase, Name: d.name}
+		if mayDrop, err := conn.mayDrop(managed); err != nil || !mayDrop {
+			return err
+		}
+		now := time.Now()
+		deletedName, err := softDeletedName(d.name, now)
+		if err != nil {
+			return err
+		}
+		comment, err := conn.runQueryGetOneField(
+			"SELECT COALESCE(shobj_description(oid, 'pg_database'), '') FROM pg_database WHERE datname = $1", d.name)
+		if err != nil {
+			return err
+		}
+		for _, query := range []string{
+			fmt.Sprintf("REVOKE CONNECT ON DATABASE %s FROM PUBLIC", identifier(d.name)),
+			fmt.Sprintf("ALTER DATABASE %s ALLOW_CONNECTIONS false", identifier(d.name)),
+		} {
+			if err = conn.runQueryExec(query); err != nil {
+				return err
+			}
+		}
+		renameQuery := fmt.Sprintf("ALTER DATABASE %s RENAME TO %s", identifier(d.name), identifier(deletedName))
+		err = conn.runQueryExec(renameQuery)
+		if err != nil && d.Force.Enabled {
+			log.Warnf("Could not rename database '%s' (%v), terminating sessions", d.name, err)
+			err = d.forceRetry(conn, func() error { return conn.runQueryExec(renameQuery) })
+		}
+		if err != nil {
+			return err
+		}
+		err = conn.runQueryExec(fmt.Sprintf("COMMENT ON DATABASE %s IS %s", identifier(deletedName),
+			quotedSQLValue(softDeleteComment(now, comment))))
+		if err != nil {
+			return err
+		}
+		if err = conn.renameManaged(managed, deletedName); err != nil {
+			return err
+		}
+		log.Infof("Database '%s' soft deleted as '%s'", d.name, deletedName)
+	}
+	return d.purgeSoftDeleted(conn)
+}
+
+// purgeSoftDeleted drops all soft deleted copies of the database that are older than the retention period
+func (d Database) purgeSoftDeleted(conn Conn) (err error) {
+	copies, err := softDeletedCopies(conn, softDeletedDatabasesQuery, d.name)
+	if err != nil {
+		return err
+	}
+	for _, deleted := range copies {
+		if !d.SoftDelete.expired(deleted.deletedAt, time.Now()) {
+			log.Debugf("Soft deleted database '%s' is kept until the retention period has passed", deleted.name)
+			continue
+		}
+		expired := Database{name: deleted.name, State: Absent, Force: d.Force}
+		if err = expired.drop(conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreSoftDeleted renames the newest soft deleted copy back, and returns true when a copy was restored
+func (d Database) restoreSoftDeleted(conn Conn) (restored bool, err error) {
+	copies, err := softDeletedCopies(conn, softDeletedDatabasesQuery, d.name)
+	if err != nil || len(copies) == 0 {
+		return false, err
+	}
+	deletedName := copies[0].name
+	for _, query := range []string{
+		fmt.Sprintf("ALTER DATABASE %s RENAME TO %s", identifier(deletedName), identifier(d.name)),
+		fmt.Sprintf("ALTER DATABASE %s ALLOW_CONNECTIONS true", identifier(d.name)),
+		fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO PUBLIC", identifier(d.name)),
+		fmt.Sprintf("COMMENT ON DATABASE %s IS %s", identifier(d.name), commentSQL(copies[0].comment)),
+	} {
+		if err = conn.runQueryExec(query); err != nil {
+			return false, err
+		}
+	}
+	if err = conn.renameManaged(ManagedObject{Kind: ObjectKindDatabase, Name: deletedName}, d.name); err != nil {
+		return false, err
+	}
+	log.Infof("Database '%s' restored from '%s'", d.name, deletedName)
+	return true, nil
+}
+
+// softDelete sets NOLOGIN, renames the role and records the time of deletion in its comment.
+// It also drops soft deleted copies after the retention period.
+func (r Role) softDelete(c Conn) (err error) {
+	existsQuery := "SELECT rolname FROM pg_roles WHERE rolname = $1 AND rolname != CURRENT_USER"
+	exists, err := c.runQueryExists(existsQuery, r.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		managed := ManagedObject{Kind: ObjectKindRole, Name: r.Name}
+		if mayDrop, err := c.mayDrop(managed); err != nil || !mayDrop {
+			return err
+		}
+		now := time.Now()
+		deletedName, err := softDeletedName(r.Name, now)
+		if err != nil {
+			return err
+		}
+		comment, err := c.runQueryGetOneField(
+			"SELECT COALESCE(shobj_description(oid, 'pg_authid'), '') FROM pg_roles WHERE rolname = $1", r.Name)
+		if err != nil {
+			return err
+		}
+		for _, query := range []string{
+			fmt.Sprintf("ALTER ROLE %s NOLOGIN", identifier(r.Name)),
+			fmt.Sprintf("ALTER ROLE %s RENAME TO %s", identifier(r.Name), identifier(deletedName)),
+			fmt.Sprintf("COMMENT ON ROLE %s IS %s", identifier(deletedName),
+				quotedSQLValue(softDeleteComment(now, comment))),
+		} {
+			if err = c.runQueryExec(query); err != nil {
+				return err
+			}
+		}
+		if err = c.renameManaged(managed, deletedName); err != nil {
+			return err
+		}
+		log.Infof("Role '%s' soft deleted as '%s'", r.Name, deletedName)
+	}
+	return r.purgeSoftDeleted(c)
+}
+
+// purgeSoftDeleted drops all soft deleted copies of the role that are older than the retention period
+func (r Role) purgeSoftDeleted(c Conn) (err error) {
+	copies, err := softDeletedCopies(c, softDeletedRolesQuery, r.Name)
+	if err != nil {
+		return err
+	}
+	for _, deleted := range copies {
+		if !r.SoftDelete.expired(deleted.deletedAt, time.Now()) {
+			log.Debugf("Soft deleted role '%s' is kept until the retention period has passed", deleted.name)
+			continue
+		}
+		expired := Role{Name: deleted.name, State: Absent, ReassignTo: r.ReassignTo, Force: r.Force}
+		if err = expired.drop(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreSoftDeleted renames the newest soft deleted copy back, and returns true when a copy was restored.
+// LOGIN and the password are restored when the options and password of the role are reconciled.
+func (r Role) restoreSoftDeleted(c Conn) (restored bool, err error) {
+	copies, err := softDeletedCopies(c, softDeletedRolesQuery, r.Name)
+	if err != nil || len(copies) == 0 {
+		return false, err
+	}
+	deletedName := copies[0].name
+	md5Password, err := hasMD5Password(c, deletedName)
+	if err != nil {
+		return false, err
+	}
+	for _, query := range []string{
+		fmt.Sprintf("ALTER ROLE %s RENAME TO %s", identifier(deletedName), identifier(r.Name)),
+		fmt.Sprintf("COMMENT ON ROLE %s IS %s", identifier(r.Name), commentSQL(copies[0].comment)),
+	} {
+		if err = c.runQueryExec(query); err != nil {
+			return false, err
+		}
+	}
+	if err = c.renameManaged(ManagedObject{Kind: ObjectKindRole, Name: deletedName}, r.Name); err != nil {
+		return false, err
+	}
+	log.Infof("Role '%s' restored from '%s'", r.Name, deletedName)
+	if md5Password {
+		return true, r.setClearedPassword(c)
+	}
+	return true, nil
+}
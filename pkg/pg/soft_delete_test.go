@@ -0,0 +1,75 @@
+package pg
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+var _ = Describe("Pkg/Pg/SoftDelete", func() {
+	deletedAt := time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC)
+	Context("UnmarshalYAML", func() {
+		It("should accept a boolean and a map", func() {
+			var sd SoftDelete
+			Ω(yaml.Unmarshal([]byte("true"), &sd)).To(Succeed())
+			Ω(sd).To(Equal(SoftDelete{Enabled: true}))
+			Ω(yaml.Unmarshal([]byte("enabled: true\nretention_days: 7\n"), &sd)).To(Succeed())
+			Ω(sd).To(Equal(SoftDelete{Enabled: true, RetentionDays: 7}))
+			Ω(yaml.Unmarshal([]byte("retention_days: -1\n"), &sd)).NotTo(Succeed())
+		})
+	})
+	Context("expired", func() {
+		It("should use the retention period", func() {
+			Ω(SoftDelete{}.expired(deletedAt, deletedAt.AddDate(0, 0, 29))).To(BeFalse())
+			Ω(SoftDelete{}.expired(deletedAt, deletedAt.AddDate(0, 0, 30))).To(BeTrue())
+			Ω(SoftDelete{RetentionDays: 1}.expired(deletedAt, deletedAt.AddDate(0, 0, 1))).To(BeTrue())
+		})
+	})
+	Context("softDeletedName", func() {
+		It("should add the date", func() {
+			Ω(softDeletedName("app", deletedAt)).To(Equal("app_deleted_20300102"))
+		})
+		It("should refuse names that become too long", func() {
+			_, err := softDeletedName(strings.Repeat("a", 50), deletedAt)
+			Ω(err).To(HaveOccurred())
+		})
+	})
+	Context("softDeleteComment", func() {
+		It("should record the time of deletion and keep the original comment", func() {
+			comment := softDeleteComment(deletedAt, "the app database")
+			Ω(comment).To(Equal("pgfga: soft deleted at 2030-01-02T15:00:00Z\nthe app database"))
+			at, original, ok := parseSoftDeleteComment(comment)
+			Ω(ok).To(BeTrue())
+			Ω(at).To(BeTemporally("==", deletedAt))
+			Ω(original).To(Equal("the app database"))
+		})
+		It("should not parse other comments", func() {
+			_, _, ok := parseSoftDeleteComment("the app database")
+			Ω(ok).To(BeFalse())
+		})
+	})
+	Context("parseSoftDeletedCopies", func() {
+		It("should only return soft deleted copies, newest first", func() {
+			copies := parseSoftDeletedCopies("app", []softDeletedCandidate{
+				{name: "app_deleted_20290101"},
+				{name: "app_deleted_20300102", comment: softDeleteComment(deletedAt, "")},
+				{name: "app_deleted_old"},
+				{name: "app_deleted_20300102_other"},
+			})
+			Ω(copies).To(Equal([]softDeletedCopy{
+				{name: "app_deleted_20300102", deletedAt: deletedAt},
+				{name: "app_deleted_20290101", deletedAt: time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC)},
+			}))
+		})
+	})
+	Context("softDeletedCopies", func() {
+		It("should connect before querying", func() {
+			unreachable := NewConn(ConnParams{"host": "/nonexistent"})
+			_, err := softDeletedCopies(unreachable, softDeletedRolesQuery, "app")
+			Ω(err).To(HaveOccurred())
+		})
+	})
+})
@@ -1,9 +1,20 @@
 package pg
 
-// StrictOptions can be set to have PgFga remove undefined users, databases, extensions or slots
+// StrictOptions can be set to have PgFga remove undefined users, databases, extensions, slots or memberships
 type StrictOptions struct {
 	Users      bool `yaml:"users"`
 	Databases  bool `yaml:"databases"`
 	Extensions bool `yaml:"extensions"`
 	Slots      bool `yaml:"replication_slots"`
+	// RoleOptions makes the options of all users and roles in the config authoritative
+	RoleOptions bool `yaml:"role_options"`
+	// ManagedOnly registers all objects that PgFga creates, and only allows PgFga to drop registered objects
+	ManagedOnly bool `yaml:"managed_only"`
+	// AdoptExisting registers existing roles, databases, schemas and replication slots that are defined in the config as
+	// managed as well (with ManagedOnly), so that objects that were created before can be dropped later
+	AdoptExisting bool `yaml:"adopt_existing"`
+	// Memberships makes the memberships of all users and roles in the config authoritative
+	Memberships bool `yaml:"memberships"`
+	// PreloadLibraries fails creating an extension when the library it requires is not in shared_preload_libraries
+	PreloadLibraries bool `yaml:"preload_libraries"`
 }
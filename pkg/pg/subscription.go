@@ -0,0 +1,236 @@
+package pg
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/pgvillage-tools/pgfga/pkg/credential"
+)
+
+// Subscriptions is a map of all subscriptions in a database, where the key is the name of the subscription
+type Subscriptions map[string]Subscription
+
+// reconcile creates, alters and drops all subscriptions
+func (ss Subscriptions) reconcile(dbConn *Conn) (err error) {
+	for _, subName := range slices.Sorted(maps.Keys(ss)) {
+		sub := ss[subName]
+		sub.name = subName
+		if err = sub.reconcile(dbConn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subscription defines a subscription for logical replication
+type Subscription struct {
+	name  string
+	State State `yaml:"state"`
+	// Connection is the connection string to the publisher (which can hold a password, hence a credential)
+	Connection   credential.Credential `yaml:"connection"`
+	Publications []string              `yaml:"publications"`
+	// Enabled defines if the subscription is replicating (defaults to true)
+	Enabled *bool `yaml:"enabled"`
+	// SlotName is the name of the replication slot on the publisher (defaults to the name of the subscription)
+	SlotName string `yaml:"slot_name"`
+	// CopyData copies existing data when the subscription is created, or when publications are added (defaults to
+	// true)
+	CopyData *bool `yaml:"copy_data"`
+}
+
+func (s Subscription) enabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+func (s Subscription) copyData() bool {
+	return s.CopyData == nil || *s.CopyData
+}
+
+func (s Subscription) publicationsSQL() string {
+	var publications []string
+	for _, publication := range slices.Sorted(slices.Values(s.Publications)) {
+		publications = append(publications, identifier(publication))
+	}
+	return strings.Join(publications, ", ")
+}
+
+// createQuery returns the query to create the subscription
+func (s Subscription) createQuery(conninfo string) string {
+	options := []string{
+		fmt.Sprintf("enabled = %t", s.enabled()),
+		fmt.Sprintf("copy_data = %t", s.copyData()),
+	}
+	if s.SlotName != "" {
+		options = append(options, "slot_name = "+quotedSQLValue(s.SlotName))
+	}
+	return fmt.Sprintf("CREATE SUBSCRIPTION %s CONNECTION %s PUBLICATION %s WITH (%s)",
+		identifier(s.name), quotedSQLValue(conninfo), s.publicationsSQL(), strings.Join(options, ", "))
+}
+
+func (s Subscription) exists(conn *Conn) (exists bool, err error) {
+	return conn.runQueryExists(
+		`SELECT subname FROM pg_subscription
+		WHERE subname = $1 AND subdbid = (SELECT oid FROM pg_database WHERE datname = current_database())`,
+		s.name)
+}
+
+// currentField returns a column of the subscription in pg_subscription as text
+func (s Subscription) currentField(conn *Conn, column string) (value string, err error) {
+	return conn.runQueryGetOneField(fmt.Sprintf(
+		`SELECT coalesce(%s::text, '') FROM pg_subscription
+		WHERE subname = $1 AND subdbid = (SELECT oid FROM pg_database WHERE datname = current_database())`,
+		column),
+		s.name)
+}
+
+func (s Subscription) reconcile(conn *Conn) (err error) {
+	if s.State != Present {
+		return s.drop(conn)
+	}
+	if len(s.Publications) == 0 {
+		return fmt.Errorf("subscription %s requires at least one publication", s.name)
+	}
+	conninfo, err := s.Connection.GetCred()
+	if err != nil {
+		return fmt.Errorf("invalid connection for subscription %s: %w", s.name, err)
+	}
+	exists, err := s.exists(conn)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err = conn.runQueryExec(s.createQuery(conninfo)); err != nil {
+			return err
+		}
+		log.Infof("Subscription '%s'.'%s' successfully created", conn.DBName(), s.name)
+		return nil
+	}
+	for _, recFunc := range []func(*Conn) error{
+		func(conn *Conn) error { return s.reconcileConnection(conn, conninfo) },
+		s.reconcileSlotName,
+		s.reconcilePublications,
+		s.reconcileEnabled,
+	} {
+		if err = recFunc(conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s Subscription) drop(conn *Conn) (err error) {
+	exists, err := s.exists(conn)
+	if err != nil || !exists {
+		return err
+	}
+	if err = conn.runQueryExec("DROP SUBSCRIPTION " + identifier(s.name)); err != nil {
+		return err
+	}
+	log.Infof("Subscription '%s'.'%s' successfully dropped", conn.DBName(), s.name)
+	return nil
+}
+
+func (s Subscription) reconcileConnection(conn *Conn, conninfo string) (err error) {
+	current, err := s.currentField(conn, "subconninfo")
+	if err != nil || current == conninfo {
+		return err
+	}
+	err = conn.runQueryExec(fmt.Sprintf("ALTER SUBSCRIPTION %s CONNECTION %s",
+		identifier(s.name), quotedSQLValue(conninfo)))
+	if err != nil {
+		return err
+	}
+	// The connection string is not logged, since it can hold a password
+	log.Infof("Connection of subscription '%s'.'%s' successfully changed", conn.DBName(), s.name)
+	return nil
+}
+
+// reconcileSlotName changes the slot of the subscription, which is only possible while it is disabled
+func (s Subscription) reconcileSlotName(conn *Conn) (err error) {
+	if s.SlotName == "" {
+		return nil
+	}
+	current, err := s.currentField(conn, "subslotname")
+	if err != nil || current == s.SlotName {
+		return err
+	}
+	enabled, err := s.currentField(conn, "subenabled")
+	if err != nil {
+		return err
+	}
+	if enabled == "true" {
+		return fmt.Errorf("slot_name of subscription %s in database %s can only be changed from %s to %s while "+
+			"it is disabled", s.name, conn.DBName(), current, s.SlotName)
+	}
+	err = conn.runQueryExec(fmt.Sprintf("ALTER SUBSCRIPTION %s SET (slot_name = %s)",
+		identifier(s.name), quotedSQLValue(s.SlotName)))
+	if err != nil {
+		return err
+	}
+	log.Infof("Subscription '%s'.'%s' now uses slot '%s'", conn.DBName(), s.name, s.SlotName)
+	return nil
+}
+
+func (s Subscription) reconcilePublications(conn *Conn) (err error) {
+	current, err := conn.runQueryGetOneColumn(
+		`SELECT unnest(subpublications) FROM pg_subscription
+		WHERE subname = $1 AND subdbid = (SELECT oid FROM pg_database WHERE datname = current_database())`,
+		s.name)
+	if err != nil {
+		return err
+	}
+	if slices.Equal(slices.Sorted(slices.Values(current)), slices.Sorted(slices.Values(s.Publications))) {
+		return nil
+	}
+	enabled, err := s.currentField(conn, "subenabled")
+	if err != nil {
+		return err
+	}
+	// A disabled subscription cannot be refreshed. reconcileEnabled refreshes its tables when pgfga enables it (a
+	// subscription that is enabled otherwise needs ALTER SUBSCRIPTION ... REFRESH PUBLICATION).
+	options := "refresh = false"
+	if enabled == "true" {
+		options = fmt.Sprintf("copy_data = %t", s.copyData())
+	}
+	err = conn.runQueryExec(fmt.Sprintf("ALTER SUBSCRIPTION %s SET PUBLICATION %s WITH (%s)",
+		identifier(s.name), s.publicationsSQL(), options))
+	if err != nil {
+		return err
+	}
+	log.Infof("Subscription '%s'.'%s' now subscribes to %s", conn.DBName(), s.name,
+		strings.Join(s.Publications, ", "))
+	return nil
+}
+
+func (s Subscription) reconcileEnabled(conn *Conn) (err error) {
+	current, err := s.currentField(conn, "subenabled")
+	if err != nil || (current == "true") == s.enabled() {
+		return err
+	}
+	action := "DISABLE"
+	if s.enabled() {
+		action = "ENABLE"
+	}
+	if err = conn.runQueryExec(fmt.Sprintf("ALTER SUBSCRIPTION %s %s", identifier(s.name), action)); err != nil {
+		return err
+	}
+	log.Infof("Subscription '%s'.'%s' successfully %sd", conn.DBName(), s.name, strings.ToLower(action))
+	if !s.enabled() {
+		return nil
+	}
+	// Publications that changed while the subscription was disabled were set without a refresh
+	return s.refresh(conn)
+}
+
+// refresh fetches the tables of the publications, and starts replicating tables that were added
+func (s Subscription) refresh(conn *Conn) (err error) {
+	err = conn.runQueryExec(fmt.Sprintf("ALTER SUBSCRIPTION %s REFRESH PUBLICATION WITH (copy_data = %t)",
+		identifier(s.name), s.copyData()))
+	if err != nil {
+		return err
+	}
+	log.Infof("Subscription '%s'.'%s' successfully refreshed", conn.DBName(), s.name)
+	return nil
+}
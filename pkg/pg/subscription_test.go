@@ -0,0 +1,25 @@
+package pg
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/Subscription", func() {
+	Context("createQuery", func() {
+		It("should enable the subscription and copy data by default", func() {
+			sub := Subscription{name: "appsub", Publications: []string{"pub2", "pub1"}}
+			Ω(sub.createQuery("host=publisher dbname=app")).To(Equal(`CREATE SUBSCRIPTION "appsub" CONNECTION ` +
+				`'host=publisher dbname=app' PUBLICATION "pub1", "pub2" WITH (enabled = true, copy_data = true)`))
+		})
+		It("should set the options that are defined", func() {
+			disabled := false
+			sub := Subscription{
+				name: "appsub", Publications: []string{"pub1"}, Enabled: &disabled, CopyData: &disabled,
+				SlotName: "appslot",
+			}
+			Ω(sub.createQuery("host=publisher")).To(HaveSuffix(
+				`WITH (enabled = false, copy_data = false, slot_name = 'appslot')`))
+		})
+	})
+})
@@ -0,0 +1,245 @@
+package pg
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Tablespaces is a map of all known Tablespace objects
+type Tablespaces map[string]Tablespace
+
+// reconcile can be used to create or alter all Tablespaces.
+func (tss Tablespaces) reconcile(primaryConn Conn) (err error) {
+	for tsName, ts := range tss {
+		ts.name = tsName
+		err := ts.reconcile(primaryConn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finalize can be used to drop all Tablespaces that should be absent.
+func (tss Tablespaces) finalize(primaryConn Conn) (err error) {
+	for tsName, ts := range tss {
+		ts.name = tsName
+		err := ts.drop(primaryConn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TablespaceOptions holds tablespace parameters (e.a. random_page_cost) as key, value pairs
+type TablespaceOptions map[string]string
+
+// tablespaceOptionsFromPg parses options as stored in pg_tablespace.spcoptions (e.a. random_page_cost=1.1)
+func tablespaceOptionsFromPg(pgOptions []string) TablespaceOptions {
+	options := TablespaceOptions{}
+	for _, pgOption := range pgOptions {
+		key, value, _ := strings.Cut(pgOption, "=")
+		options[key] = value
+	}
+	return options
+}
+
+// setSQL returns the SQL part to set all options (e.a. `random_page_cost = '1.1'`)
+func (tso TablespaceOptions) setSQL() string {
+	var pairs []string
+	for _, key := range slices.Sorted(maps.Keys(tso)) {
+		pairs = append(pairs, fmt.Sprintf("%s = %s", identifier(key), quotedSQLValue(tso[key])))
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// resetSQL returns the SQL part to reset all options (e.a. `random_page_cost`)
+func (tso TablespaceOptions) resetSQL() string {
+	var keys []string
+	for _, key := range slices.Sorted(maps.Keys(tso)) {
+		keys = append(keys, identifier(key))
+	}
+	return strings.Join(keys, ", ")
+}
+
+// Tablespace is a struct that can hold tablespace information
+type Tablespace struct {
+	// for tablespaces created from yaml, name is set by the pg.Handler
+	name     string
+	Location string            `yaml:"location"`
+	Owner    string            `yaml:"owner"`
+	Options  TablespaceOptions `yaml:"options"`
+	State    State             `yaml:"state"`
+}
+
+// reconcile can be used to create the tablespace and set owner and options
+func (ts Tablespace) reconcile(conn Conn) (err error) {
+	if ts.State != Present {
+		return nil
+	}
+	for _, recFunc := range []func(Conn) error{
+		ts.create,
+		ts.reconcileOwner,
+		ts.reconcileOptions,
+	} {
+		err := recFunc(conn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exists can be used to check if the tablespace exists
+func (ts Tablespace) exists(conn Conn) (exists bool, err error) {
+	return conn.runQueryExists("SELECT spcname FROM pg_tablespace WHERE spcname = $1", ts.name)
+}
+
+// create can be used to make sure the tablespace exists
+func (ts Tablespace) create(conn Conn) (err error) {
+	exists, err := ts.exists(conn)
+	if err != nil {
+		return err
+	}
+	if exists {
+		log.Debugf("Tablespace '%s' already exists", ts.name)
+		return nil
+	}
+	if ts.Location == "" {
+		return fmt.Errorf("tablespace %s should have a location", ts.name)
+	}
+	createQry := "CREATE TABLESPACE " + identifier(ts.name)
+	if ts.Owner != "" {
+		if err = (Role{Name: ts.Owner, State: Present}).create(conn); err != nil {
+			return err
+		}
+		createQry += " OWNER " + identifier(ts.Owner)
+	}
+	createQry += " LOCATION " + quotedSQLValue(ts.Location)
+	err = conn.runQueryExec(createQry)
+	if err != nil {
+		return err
+	}
+	log.Infof("Tablespace '%s' successfully created", ts.name)
+	return nil
+}
+
+// reconcileOwner can be used to make sure the tablespace has the proper owner
+func (ts Tablespace) reconcileOwner(conn Conn) (err error) {
+	if ts.Owner == "" {
+		return nil
+	}
+	if hasProperOwner, err := conn.runQueryExists(
+		`SELECT spcname
+		FROM pg_tablespace spc
+		INNER JOIN pg_roles rol
+		ON spc.spcowner = rol.oid
+		WHERE spcname = $1
+		AND rolname = $2`,
+		ts.name,
+		ts.Owner,
+	); err != nil {
+		return err
+	} else if hasProperOwner {
+		return nil
+	}
+	if err = (Role{Name: ts.Owner, State: Present}).create(conn); err != nil {
+		return err
+	}
+	if err = conn.runQueryExec(
+		fmt.Sprintf("ALTER TABLESPACE %s OWNER TO %s", identifier(ts.name), identifier(ts.Owner)),
+	); err != nil {
+		return err
+	}
+	log.Infof("Tablespace Owner successfully altered to '%s' on '%s'", ts.Owner, ts.name)
+	return nil
+}
+
+func (ts Tablespace) currentOptions(conn Conn) (options TablespaceOptions, err error) {
+	pgOptions, err := conn.runQueryGetOneColumn(
+		"SELECT unnest(spcoptions) FROM pg_tablespace WHERE spcname = $1",
+		ts.name)
+	if err != nil {
+		return nil, err
+	}
+	return tablespaceOptionsFromPg(pgOptions), nil
+}
+
+// reconcileOptions sets all options that differ from the config, and resets all options that are not in the config
+func (ts Tablespace) reconcileOptions(conn Conn) (err error) {
+	current, err := ts.currentOptions(conn)
+	if err != nil {
+		return err
+	}
+	toSet := TablespaceOptions{}
+	for key, value := range ts.Options {
+		if curValue, exists := current[key]; !exists || curValue != value {
+			toSet[key] = value
+		}
+	}
+	toReset := TablespaceOptions{}
+	for key, value := range current {
+		if _, exists := ts.Options[key]; !exists {
+			toReset[key] = value
+		}
+	}
+	if len(toSet) > 0 {
+		err = conn.runQueryExec(fmt.Sprintf("ALTER TABLESPACE %s SET (%s)", identifier(ts.name), toSet.setSQL()))
+		if err != nil {
+			return err
+		}
+		log.Infof("Tablespace '%s' successfully altered with options %s", ts.name, toSet.setSQL())
+	}
+	if len(toReset) > 0 {
+		err = conn.runQueryExec(
+			fmt.Sprintf("ALTER TABLESPACE %s RESET (%s)", identifier(ts.name), toReset.resetSQL()))
+		if err != nil {
+			return err
+		}
+		log.Infof("Tablespace '%s' successfully reset options %s", ts.name, toReset.resetSQL())
+	}
+	return nil
+}
+
+// usedBy returns the databases that have objects in this tablespace
+func (ts Tablespace) usedBy(conn Conn) (dbNames []string, err error) {
+	return conn.runQueryGetOneColumn(
+		`SELECT datname FROM pg_database
+		WHERE oid IN (
+		  SELECT pg_tablespace_databases(oid) FROM pg_tablespace WHERE spcname = $1)
+		ORDER BY datname`,
+		ts.name)
+}
+
+// drop can be used to drop the tablespace. Drop refuses when the tablespace is still in use.
+func (ts *Tablespace) drop(conn Conn) (err error) {
+	if ts.State != Absent {
+		return nil
+	}
+	exists, err := ts.exists(conn)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		log.Debugf("Tablespace '%s' already gone", ts.name)
+		return nil
+	}
+	dbNames, err := ts.usedBy(conn)
+	if err != nil {
+		return err
+	}
+	if len(dbNames) > 0 {
+		return fmt.Errorf("tablespace %s still contains objects from databases %s",
+			ts.name, strings.Join(dbNames, ", "))
+	}
+	err = conn.runQueryExec(fmt.Sprintf("DROP TABLESPACE %s", identifier(ts.name)))
+	if err != nil {
+		return err
+	}
+	ts.State = Absent
+	log.Infof("Tablespace '%s' successfully dropped", ts.name)
+	return nil
+}
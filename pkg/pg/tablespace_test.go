@@ -0,0 +1,27 @@
+package pg
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Pg/Tablespace", func() {
+	Context("TablespaceOptions", func() {
+		It("should parse options as stored in pg_tablespace", func() {
+			options := tablespaceOptionsFromPg([]string{"random_page_cost=1.1", "seq_page_cost=1"})
+			Ω(options).To(Equal(TablespaceOptions{"random_page_cost": "1.1", "seq_page_cost": "1"}))
+		})
+		It("should return sorted SQL for setting and resetting options", func() {
+			options := TablespaceOptions{"seq_page_cost": "1", "random_page_cost": "1.1"}
+			Ω(options.setSQL()).To(Equal(`"random_page_cost" = '1.1', "seq_page_cost" = '1'`))
+			Ω(options.resetSQL()).To(Equal(`"random_page_cost", "seq_page_cost"`))
+		})
+	})
+	Context("drop", func() {
+		It("should not drop tablespaces with State Present", func() {
+			ts := Tablespace{name: "ts_present", State: Present}
+			Ω(ts.drop(NewConn(ConnParams{}))).NotTo(HaveOccurred())
+			Ω(ts.State).To(Equal(Present))
+		})
+	})
+})
@@ -0,0 +1,181 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	pgPassWildcard   = "*"
+	pgPassFieldCount = 5
+	// pgPassChangedAt prefixes the comment line in a .pgpass file that holds the moment the password was generated
+	pgPassChangedAt = "# changed_at: "
+	// kubernetesChangedAt is the annotation on a Kubernetes Secret that holds the moment the password was generated
+	kubernetesChangedAt = "pgfga/changed-at"
+)
+
+// parseChangedAt parses the moment a password was generated (zero when it was not stored)
+func parseChangedAt(value string) (changedAt time.Time, err error) {
+	if value == "" {
+		return changedAt, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func formatChangedAt(changedAt time.Time) string {
+	if changedAt.IsZero() {
+		return ""
+	}
+	return changedAt.UTC().Format(time.RFC3339)
+}
+
+func (o Output) parsePlain(data string) (s Secret) {
+	s.Active.Password = strings.TrimSpace(data)
+	return s
+}
+
+func (o Output) renderPlain(s Secret) string {
+	return s.Active.Password + "\n"
+}
+
+func pgPassEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ":", `\:`).Replace(value)
+}
+
+// pgPassSplit splits a .pgpass line in its fields, unescaping escaped colons and backslashes
+func pgPassSplit(line string) (fields []string) {
+	var field strings.Builder
+	escaped := false
+	for _, char := range line {
+		switch {
+		case escaped:
+			field.WriteRune(char)
+			escaped = false
+		case char == '\\':
+			escaped = true
+		case char == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(char)
+		}
+	}
+	return append(fields, field.String())
+}
+
+func withDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func (o Output) pgPassLine(account Account) string {
+	return strings.Join([]string{
+		pgPassEscape(withDefault(o.Host, pgPassWildcard)),
+		pgPassEscape(withDefault(o.Port, pgPassWildcard)),
+		pgPassEscape(withDefault(o.Database, pgPassWildcard)),
+		pgPassEscape(account.User),
+		pgPassEscape(account.Password),
+	}, ":")
+}
+
+// parsePgPass reads the active account from the first line and the previous account from the second line
+func (o Output) parsePgPass(data string) (s Secret, err error) {
+	var accounts []Account
+	for line := range strings.SplitSeq(data, "\n") {
+		line = strings.TrimSpace(line)
+		if changedAt, isChangedAt := strings.CutPrefix(line, pgPassChangedAt); isChangedAt {
+			if s.ChangedAt, err = parseChangedAt(changedAt); err != nil {
+				return s, err
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := pgPassSplit(line)
+		if len(fields) != pgPassFieldCount {
+			return s, fmt.Errorf("invalid pgpass line with %d fields", len(fields))
+		}
+		accounts = append(accounts, Account{User: fields[3], Password: fields[4]})
+	}
+	if len(accounts) == 0 {
+		return s, errors.New("no pgpass lines found")
+	}
+	s.Active = accounts[0]
+	if len(accounts) > 1 {
+		s.Previous = accounts[1]
+	}
+	return s, nil
+}
+
+func (o Output) renderPgPass(s Secret) string {
+	var rendered string
+	if changedAt := formatChangedAt(s.ChangedAt); changedAt != "" {
+		rendered = pgPassChangedAt + changedAt + "\n"
+	}
+	rendered += o.pgPassLine(s.Active) + "\n"
+	if s.Previous.User != "" {
+		rendered += o.pgPassLine(s.Previous) + "\n"
+	}
+	return rendered
+}
+
+type kubernetesMetadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type kubernetesStringData struct {
+	Username         string `yaml:"username"`
+	Password         string `yaml:"password"`
+	PreviousUsername string `yaml:"previous-username,omitempty"`
+	PreviousPassword string `yaml:"previous-password,omitempty"`
+}
+
+type kubernetesSecret struct {
+	APIVersion string               `yaml:"apiVersion"`
+	Kind       string               `yaml:"kind"`
+	Metadata   kubernetesMetadata   `yaml:"metadata"`
+	Type       string               `yaml:"type"`
+	StringData kubernetesStringData `yaml:"stringData"`
+}
+
+func (o Output) parseKubernetes(data []byte) (s Secret, err error) {
+	var manifest kubernetesSecret
+	if err = yaml.Unmarshal(data, &manifest); err != nil {
+		return s, err
+	}
+	if manifest.StringData.Username == "" || manifest.StringData.Password == "" {
+		return s, errors.New("no username and password in stringData")
+	}
+	s.Active = Account{User: manifest.StringData.Username, Password: manifest.StringData.Password}
+	s.Previous = Account{User: manifest.StringData.PreviousUsername, Password: manifest.StringData.PreviousPassword}
+	s.ChangedAt, err = parseChangedAt(manifest.Metadata.Annotations[kubernetesChangedAt])
+	return s, err
+}
+
+func (o Output) renderKubernetes(s Secret) ([]byte, error) {
+	metadata := kubernetesMetadata{Name: o.Name, Namespace: o.Namespace}
+	if changedAt := formatChangedAt(s.ChangedAt); changedAt != "" {
+		metadata.Annotations = map[string]string{kubernetesChangedAt: changedAt}
+	}
+	return yaml.Marshal(kubernetesSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   metadata,
+		Type:       "kubernetes.io/basic-auth",
+		StringData: kubernetesStringData{
+			Username:         s.Active.User,
+			Password:         s.Active.Password,
+			PreviousUsername: s.Previous.User,
+			PreviousPassword: s.Previous.Password,
+		},
+	})
+}
@@ -0,0 +1,174 @@
+// Package secret can be used to generate passwords and to read and write them to secret outputs (like a file, a
+// .pgpass file or a Kubernetes Secret manifest)
+package secret
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DefaultPasswordLength is the length of generated passwords when no length is set
+	DefaultPasswordLength = 32
+	passwordCharset       = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	secretFileMode        = 0o600
+)
+
+// Account is a username and password combination
+type Account struct {
+	User     string
+	Password string
+}
+
+// Secret holds the active account, and optionally the previous account (which is kept valid during rotation).
+type Secret struct {
+	Active   Account
+	Previous Account
+	// ChangedAt is the moment the password was generated. It is stored in the output (when the format supports it),
+	// so that copying or restoring the output does not change the rotation schedule.
+	ChangedAt time.Time
+}
+
+// GeneratePassword returns a random password of the requested length
+func GeneratePassword(length int) (password string, err error) {
+	if length < 1 {
+		length = DefaultPasswordLength
+	}
+	charsetLength := big.NewInt(int64(len(passwordCharset)))
+	generated := make([]byte, length)
+	for i := range generated {
+		index, err := rand.Int(rand.Reader, charsetLength)
+		if err != nil {
+			return "", err
+		}
+		generated[i] = passwordCharset[index.Int64()]
+	}
+	return string(generated), nil
+}
+
+// OutputFormat defines how a secret is written to the output file
+type OutputFormat string
+
+const (
+	// OutputFormatPlain writes only the password to the output file
+	OutputFormatPlain OutputFormat = "file"
+	// OutputFormatPgPass writes the accounts as .pgpass lines (hostname:port:database:username:password)
+	OutputFormatPgPass OutputFormat = "pgpass"
+	// OutputFormatKubernetes writes the accounts as a Kubernetes Secret manifest
+	OutputFormatKubernetes OutputFormat = "kubernetes"
+)
+
+// Output defines where and how a secret is written.
+type Output struct {
+	Format OutputFormat `yaml:"format"`
+	Path   string       `yaml:"path"`
+	// Host, Port and Database are used for pgpass lines and default to *
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Database string `yaml:"database"`
+	// Name and Namespace are used for Kubernetes Secret manifests
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+// Validate checks if the output is properly configured
+func (o Output) Validate() error {
+	if o.Path == "" {
+		return errors.New("path must be set for a secret output")
+	}
+	switch o.format() {
+	case OutputFormatPlain, OutputFormatPgPass:
+		return nil
+	case OutputFormatKubernetes:
+		if o.Name == "" {
+			return errors.New("name must be set for a secret output with format kubernetes")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid secret output format %s (should be file, pgpass or kubernetes)", o.Format)
+}
+
+// SupportsUser returns true if the output format also holds the username (which is required for rotation with a
+// previous account)
+func (o Output) SupportsUser() bool {
+	return o.format() != OutputFormatPlain
+}
+
+// SupportsChangedAt returns true if the output format also holds the moment the password was generated (which is
+// required for rotation). A plain file only holds the password.
+func (o Output) SupportsChangedAt() bool {
+	return o.format() != OutputFormatPlain
+}
+
+func (o Output) format() OutputFormat {
+	if o.Format == "" {
+		return OutputFormatPlain
+	}
+	return o.Format
+}
+
+// Read reads the secret from the output. exists is false when the output file does not exist (yet).
+func (o Output) Read() (s Secret, exists bool, err error) {
+	// Reading a file which name is set in the config is sort of the point.
+	// #nosec
+	data, err := os.ReadFile(o.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, false, nil
+	} else if err != nil {
+		return s, false, err
+	}
+	switch o.format() {
+	case OutputFormatPgPass:
+		s, err = o.parsePgPass(string(data))
+	case OutputFormatKubernetes:
+		s, err = o.parseKubernetes(data)
+	default:
+		s = o.parsePlain(string(data))
+	}
+	if err != nil {
+		return s, false, fmt.Errorf("could not parse secret output %s: %w", o.Path, err)
+	}
+	return s, true, nil
+}
+
+// Write writes the secret to the output (atomically and with 0600 permissions)
+func (o Output) Write(s Secret) (err error) {
+	var data []byte
+	switch o.format() {
+	case OutputFormatPgPass:
+		data = []byte(o.renderPgPass(s))
+	case OutputFormatKubernetes:
+		if data, err = o.renderKubernetes(s); err != nil {
+			return err
+		}
+	default:
+		data = []byte(o.renderPlain(s))
+	}
+	return writeFileAtomic(o.Path, data)
+}
+
+// writeFileAtomic writes to a temporary file in the same directory and renames it to the destination
+func writeFileAtomic(path string, data []byte) (err error) {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if err = tmpFile.Chmod(secretFileMode); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if _, err = tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
@@ -0,0 +1,106 @@
+package secret_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pgvillage-tools/pgfga/pkg/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	const length = 20
+	password, err := secret.GeneratePassword(length)
+	require.NoError(t, err)
+	assert.Len(t, password, length)
+	other, err := secret.GeneratePassword(length)
+	require.NoError(t, err)
+	assert.NotEqual(t, password, other)
+	password, err = secret.GeneratePassword(0)
+	require.NoError(t, err)
+	assert.Len(t, password, secret.DefaultPasswordLength)
+}
+
+func TestOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	mySecret := secret.Secret{
+		Active:    secret.Account{User: "app_2", Password: "new:pass"},
+		Previous:  secret.Account{User: "app_1", Password: `old\pass`},
+		ChangedAt: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	for _, output := range []secret.Output{
+		{Format: secret.OutputFormatPgPass, Path: path.Join(tmpDir, "pgpass"), Host: "db1", Port: "5432"},
+		{Format: secret.OutputFormatKubernetes, Path: path.Join(tmpDir, "secret.yaml"), Name: "app",
+			Namespace: "apps"},
+	} {
+		t.Logf("output: %v", output)
+		require.NoError(t, output.Validate())
+		assert.True(t, output.SupportsUser())
+		assert.True(t, output.SupportsChangedAt())
+		_, exists, err := output.Read()
+		require.NoError(t, err)
+		assert.False(t, exists)
+		require.NoError(t, output.Write(mySecret))
+		fi, err := os.Stat(output.Path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+		read, exists, err := output.Read()
+		require.NoError(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, mySecret.Active, read.Active)
+		assert.Equal(t, mySecret.Previous, read.Previous)
+		assert.Equal(t, mySecret.ChangedAt, read.ChangedAt)
+		// The rotation time is stored in the secret, so touching the output does not change it
+		require.NoError(t, os.Chtimes(output.Path, time.Now(), time.Now()))
+		read, _, err = output.Read()
+		require.NoError(t, err)
+		assert.Equal(t, mySecret.ChangedAt, read.ChangedAt)
+	}
+}
+
+func TestPlainOutput(t *testing.T) {
+	output := secret.Output{Path: path.Join(t.TempDir(), "password")}
+	require.NoError(t, output.Validate())
+	assert.False(t, output.SupportsUser())
+	assert.False(t, output.SupportsChangedAt())
+	require.NoError(t, output.Write(secret.Secret{Active: secret.Account{User: "app", Password: "pass"}}))
+	data, err := os.ReadFile(output.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "pass", strings.TrimSpace(string(data)))
+	read, exists, err := output.Read()
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, secret.Account{Password: "pass"}, read.Active)
+}
+
+func TestPgPassLine(t *testing.T) {
+	output := secret.Output{Format: secret.OutputFormatPgPass, Path: path.Join(t.TempDir(), "pgpass")}
+	require.NoError(t, output.Write(secret.Secret{Active: secret.Account{User: "app", Password: "a:b"}}))
+	data, err := os.ReadFile(output.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "*:*:*:app:a\\:b\n", string(data))
+}
+
+func TestPgPassChangedAt(t *testing.T) {
+	output := secret.Output{Format: secret.OutputFormatPgPass, Path: path.Join(t.TempDir(), "pgpass")}
+	changedAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, output.Write(secret.Secret{Active: secret.Account{User: "app", Password: "pass"},
+		ChangedAt: changedAt}))
+	data, err := os.ReadFile(output.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "# changed_at: 2030-01-01T00:00:00Z\n*:*:*:app:pass\n", string(data))
+}
+
+func TestOutputValidate(t *testing.T) {
+	for _, output := range []secret.Output{
+		{},
+		{Format: "vault", Path: "/tmp/secret"},
+		{Format: secret.OutputFormatKubernetes, Path: "/tmp/secret.yaml"},
+	} {
+		assert.Error(t, output.Validate())
+	}
+}